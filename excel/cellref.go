@@ -0,0 +1,268 @@
+package excel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CellRef : a parsed A1 reference such as "B2", "$B$2" or "'Sheet 1'!B2"
+type CellRef struct {
+	Sheet  string // empty if the reference is not sheet-qualified
+	Row    int    // [1,1048576]
+	Col    int    // [1,16384]
+	AbsRow bool   // $2
+	AbsCol bool   // $B
+}
+
+// ParseCellRef : "AB12", "$AB$12" or "Sheet1!AB12" to row = 12, col = 28
+func ParseCellRef(ref string) (row, col int, err error) {
+	c, err := ParseReference(ref)
+	if err != nil {
+		return 0, 0, err
+	}
+	return c.Row, c.Col, nil
+}
+
+// ParseReference : like ParseCellRef but keep the sheet name and the $ markers
+func ParseReference(ref string) (CellRef, error) {
+	sheet, cell, err := splitSheet(ref)
+	if err != nil {
+		return CellRef{}, err
+	}
+	c := CellRef{Sheet: sheet}
+	s := strings.ToUpper(strings.TrimSpace(cell))
+	if c.AbsCol, c.Col, s, err = parsePart(s, true); err != nil || c.Col == 0 {
+		return CellRef{}, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	if c.AbsRow, c.Row, s, err = parsePart(s, false); err != nil || c.Row == 0 || s != "" {
+		return CellRef{}, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return c, nil
+}
+
+// String : "B2", "$B$2", "'Sheet 1'!B2"
+func (c CellRef) String() string {
+	var sb strings.Builder
+	if c.Sheet != "" {
+		sb.WriteString(quoteSheetName(c.Sheet))
+		sb.WriteByte('!')
+	}
+	if c.AbsCol {
+		sb.WriteByte('$')
+	}
+	sb.WriteString(ColumnName(c.Col))
+	if c.AbsRow {
+		sb.WriteByte('$')
+	}
+	sb.WriteString(strconv.Itoa(c.Row))
+	return sb.String()
+}
+
+// ColumnName : column = [1,16384] to "A" .. "XFD", empty if column is invalid
+func ColumnName(column int) string {
+	if column <= 0 || column > MaxColumn {
+		return ""
+	}
+	return columnLetters(column)
+}
+
+// parsePart : read an optional "$" then letters (column) or digits (row) from the start of s
+func parsePart(s string, letters bool) (abs bool, n int, rest string, err error) {
+	if strings.HasPrefix(s, "$") {
+		abs = true
+		s = s[1:]
+	}
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if letters && 'A' <= c && c <= 'Z' {
+			n = n*26 + int(c-'A'+1)
+		} else if !letters && '0' <= c && c <= '9' {
+			n = n*10 + int(c-'0')
+		} else {
+			break
+		}
+		if (letters && n > MaxColumn) || (!letters && n > MaxRow) {
+			return false, 0, "", fmt.Errorf("out of range")
+		}
+	}
+	if abs && i == 0 {
+		return false, 0, "", fmt.Errorf("$ without a row or column")
+	}
+	return abs, n, s[i:], nil
+}
+
+// splitSheet : "'Sheet 1'!A1" to "Sheet 1", "A1"
+func splitSheet(ref string) (sheet, cell string, err error) {
+	ref = strings.TrimSpace(ref)
+	i := strings.LastIndexByte(ref, '!')
+	if i < 0 {
+		return "", ref, nil
+	}
+	sheet, cell = ref[:i], ref[i+1:]
+	if strings.HasPrefix(sheet, "'") {
+		if len(sheet) < 2 || !strings.HasSuffix(sheet, "'") {
+			return "", "", fmt.Errorf("invalid sheet name in %q", ref)
+		}
+		sheet = strings.ReplaceAll(sheet[1:len(sheet)-1], "''", "'")
+	}
+	if sheet == "" {
+		return "", "", fmt.Errorf("invalid sheet name in %q", ref)
+	}
+	return sheet, cell, nil
+}
+
+// quoteSheetName : wrap names that are not a plain word in apostrophes, doubling any apostrophe inside
+func quoteSheetName(name string) string {
+	plain := name != ""
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || r == '.' || (i > 0 && unicode.IsDigit(r))) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+// -----------------------------------------------------------------------------------------------------------
+
+// CellRange : rectangle of cells, rows and columns are [1,n] and inclusive
+type CellRange struct {
+	Sheet    string
+	FirstRow int
+	FirstCol int
+	LastRow  int
+	LastCol  int
+}
+
+// NewCellRange : range between two corners in any order
+func NewCellRange(first_row, first_col, last_row, last_col int) CellRange {
+	if last_row < first_row {
+		first_row, last_row = last_row, first_row
+	}
+	if last_col < first_col {
+		first_col, last_col = last_col, first_col
+	}
+	return CellRange{FirstRow: first_row, FirstCol: first_col, LastRow: last_row, LastCol: last_col}
+}
+
+// ParseCellRange : "A1:D20", "$A$1:$D$20", "'Sheet 1'!A1:D20", "B2", whole columns "A:C" or whole rows "2:5"
+func ParseCellRange(ref string) (CellRange, error) {
+	sheet, cells, err := splitSheet(ref)
+	if err != nil {
+		return CellRange{}, err
+	}
+	from, to := cells, cells
+	if i := strings.IndexByte(cells, ':'); i >= 0 {
+		from, to = cells[:i], cells[i+1:]
+	}
+
+	var r CellRange
+	if c1, err := ParseReference(from); err == nil {
+		c2, err := ParseReference(to)
+		if err != nil {
+			return CellRange{}, fmt.Errorf("invalid cell range %q", ref)
+		}
+		r = NewCellRange(c1.Row, c1.Col, c2.Row, c2.Col)
+	} else if c1, c2, ok := parseLines(from, to, true); ok {
+		r = NewCellRange(1, c1, MaxRow, c2)
+	} else if r1, r2, ok := parseLines(from, to, false); ok {
+		r = NewCellRange(r1, 1, r2, MaxColumn)
+	} else {
+		return CellRange{}, fmt.Errorf("invalid cell range %q", ref)
+	}
+	r.Sheet = sheet
+	return r, nil
+}
+
+// parseLines : "A:C" (letters) or "2:5" (digits)
+func parseLines(from, to string, letters bool) (int, int, bool) {
+	parse := func(s string) int {
+		_, n, rest, err := parsePart(strings.ToUpper(strings.TrimSpace(s)), letters)
+		if err != nil || rest != "" {
+			return 0
+		}
+		return n
+	}
+	n1, n2 := parse(from), parse(to)
+	return n1, n2, n1 > 0 && n2 > 0
+}
+
+// RowCount : number of rows in the range
+func (r CellRange) RowCount() int {
+	return r.LastRow - r.FirstRow + 1
+}
+
+// ColCount : number of columns in the range
+func (r CellRange) ColCount() int {
+	return r.LastCol - r.FirstCol + 1
+}
+
+// Contains : row, col is inside the range
+func (r CellRange) Contains(row, col int) bool {
+	return r.FirstRow <= row && row <= r.LastRow && r.FirstCol <= col && col <= r.LastCol
+}
+
+// Intersect : cells in both ranges, false if they do not overlap or are on different sheets
+func (r CellRange) Intersect(other CellRange) (CellRange, bool) {
+	if r.Sheet != "" && other.Sheet != "" && !strings.EqualFold(r.Sheet, other.Sheet) {
+		return CellRange{}, false
+	}
+	out := CellRange{
+		Sheet:    r.Sheet,
+		FirstRow: maxInt(r.FirstRow, other.FirstRow),
+		FirstCol: maxInt(r.FirstCol, other.FirstCol),
+		LastRow:  minInt(r.LastRow, other.LastRow),
+		LastCol:  minInt(r.LastCol, other.LastCol),
+	}
+	if out.Sheet == "" {
+		out.Sheet = other.Sheet
+	}
+	if out.FirstRow > out.LastRow || out.FirstCol > out.LastCol {
+		return CellRange{}, false
+	}
+	return out, true
+}
+
+// Each : call fn for every cell row by row, stop when fn returns false
+func (r CellRange) Each(fn func(row, col int) bool) {
+	for row := r.FirstRow; row <= r.LastRow; row++ {
+		for col := r.FirstCol; col <= r.LastCol; col++ {
+			if !fn(row, col) {
+				return
+			}
+		}
+	}
+}
+
+// String : "A1:D20", "B2" for a single cell, with the quoted sheet name if set,
+// cells out of the sheet are written as "R0C1"
+func (r CellRange) String() string {
+	s := cellName(r.FirstRow, r.FirstCol)
+	if r.LastRow != r.FirstRow || r.LastCol != r.FirstCol {
+		s += ":" + cellName(r.LastRow, r.LastCol)
+	}
+	if r.Sheet != "" {
+		s = quoteSheetName(r.Sheet) + "!" + s
+	}
+	return s
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
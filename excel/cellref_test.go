@@ -0,0 +1,133 @@
+package excel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCellRef(t *testing.T) {
+	cases := []struct {
+		in       string
+		row, col int
+		wantErr  bool
+	}{
+		{"A1", 1, 1, false},
+		{"AB12", 12, 28, false},
+		{"$A$1", 1, 1, false},
+		{"xfd1048576", 1048576, 16384, false},
+		{"'Sheet 1'!$C5", 5, 3, false},
+		{"A0", 0, 0, true},
+		{"XFE1", 0, 0, true},
+		{"A1048577", 0, 0, true},
+		{"1A", 0, 0, true},
+		{"A", 0, 0, true},
+		{"$", 0, 0, true},
+		{"A1B", 0, 0, true},
+		{"!A1", 0, 0, true},
+	}
+
+	for _, c := range cases {
+		row, col, err := ParseCellRef(c.in)
+		if row != c.row || col != c.col || (err != nil) != c.wantErr {
+			t.Errorf("ParseCellRef(%q) == %d, %d, %v, want %d, %d", c.in, row, col, err, c.row, c.col)
+		}
+	}
+}
+
+func TestCellRefString(t *testing.T) {
+	cases := []string{"B2", "$B$2", "B$2", "Data!B2", "'Sheet 1'!B2", "'Bob''s'!$A1", "ยอดขาย!A1", "'2021'!A1"}
+
+	for _, in := range cases {
+		c, err := ParseReference(in)
+		if err != nil {
+			t.Errorf("ParseReference(%q) error %v", in, err)
+			continue
+		}
+		if got := c.String(); got != in {
+			t.Errorf("ParseReference(%q).String() == %q, want %q", in, got, in)
+		}
+	}
+}
+
+func TestCellRangeStringInvalid(t *testing.T) {
+	cases := []struct {
+		in   CellRange
+		want string
+	}{
+		{CellRange{}, "R0C0"},
+		{CellRange{"Data", 1, 0, 2, 3}, "Data!R1C0:C2"},
+		{CellRange{"", 1, 1, 1, MaxColumn + 1}, "A1:R1C16385"},
+	}
+	for _, c := range cases {
+		if got := c.in.String(); got != c.want {
+			t.Errorf("%+v.String() == %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseCellRange(t *testing.T) {
+	cases := []struct {
+		in   string
+		want CellRange
+		str  string
+	}{
+		{"A1:D20", CellRange{"", 1, 1, 20, 4}, "A1:D20"},
+		{"D20:A1", CellRange{"", 1, 1, 20, 4}, "A1:D20"},
+		{"$A$1:$B$2", CellRange{"", 1, 1, 2, 2}, "A1:B2"},
+		{"B2", CellRange{"", 2, 2, 2, 2}, "B2"},
+		{"'Sheet 1'!A1:B2", CellRange{"Sheet 1", 1, 1, 2, 2}, "'Sheet 1'!A1:B2"},
+		{"A:C", CellRange{"", 1, 1, 1048576, 3}, "A1:C1048576"},
+		{"2:5", CellRange{"", 2, 1, 5, 16384}, "A2:XFD5"},
+	}
+
+	for _, c := range cases {
+		got, err := ParseCellRange(c.in)
+		if err != nil || got != c.want {
+			t.Errorf("ParseCellRange(%q) == %+v, %v, want %+v", c.in, got, err, c.want)
+		}
+		if s := got.String(); s != c.str {
+			t.Errorf("ParseCellRange(%q).String() == %q, want %q", c.in, s, c.str)
+		}
+	}
+
+	for _, in := range []string{"", "A1:", "A1:B", "A:1", "Sheet1!"} {
+		if _, err := ParseCellRange(in); err == nil {
+			t.Errorf("ParseCellRange(%q) == nil error, want error", in)
+		}
+	}
+}
+
+func TestCellRangeIntersect(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want string
+		ok   bool
+	}{
+		{"A1:D20", "C10:F30", "C10:D20", true},
+		{"A1:B2", "C3:D4", "", false},
+		{"A:A", "3:3", "A3", true},
+		{"Data!A1:B2", "B2:C3", "Data!B2", true},
+		{"Data!A1:B2", "Other!A1:B2", "", false},
+	}
+
+	for _, c := range cases {
+		a, _ := ParseCellRange(c.a)
+		b, _ := ParseCellRange(c.b)
+		got, ok := a.Intersect(b)
+		if ok != c.ok || (ok && got.String() != c.want) {
+			t.Errorf("Intersect(%q, %q) == %q, %v, want %q, %v", c.a, c.b, got.String(), ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestCellRangeEach(t *testing.T) {
+	r, _ := ParseCellRange("B2:C3")
+	var got []string
+	r.Each(func(row, col int) bool {
+		got = append(got, RowCol(row, col))
+		return len(got) < 3
+	})
+	if want := []string{"B2", "C2", "B3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Each(B2:C3) == %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package excel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	gosql "github.com/churikawit/gosrc/sql"
+)
+
+// WriteDataReader : write the column names of reader as a bold header at (first_row, first_col)
+// and every remaining row of reader below it, return the range written including the header
+// columns get the format set by SetColumnFormat, otherwise one by their database type (see FormatForDataType)
+// with dates in the formats set by SetDateFormat, a date cell of an opened workbook keeps its own format
+// the reader is read to the end but not closed
+func (ec *ExcelControl) WriteDataReader(reader *gosql.DataReader, first_row, first_col int) (CellRange, error) {
+	names := reader.GetNames()
+	last_col := first_col + len(names) - 1
+	if len(names) == 0 {
+		last_col = first_col
+	}
+	if _, _, err := ec.cellRef("WriteDataReader", first_row, last_col); err != nil {
+		return CellRange{}, err
+	}
+	if err := ec.WriteHeader(names, first_row, first_col); err != nil {
+		return CellRange{}, err
+	}
+	header := ec.headerStyle()
+	for i := range names {
+		ec.m_Worksheet.Cell(cellName(first_row, first_col+i)).SetStyle(header)
+	}
+
+	typeNames := make([]string, len(names))
+	formats := make([]string, len(names))
+	for i := range names {
+		typeNames[i] = strings.ToUpper(reader.GetDataTypeName(i))
+		if formats[i] = ec.columnFormat(first_col + i); formats[i] == "" {
+			formats[i] = ec.dataTypeFormat(typeNames[i])
+		}
+	}
+
+	row := first_row
+	for reader.Read() {
+		row++
+		if row > MaxRow {
+			return CellRange{}, ec.newError("WriteDataReader", cellName(first_row, first_col),
+				fmt.Errorf("more than %d rows", MaxRow-first_row))
+		}
+		for i, v := range reader.GetValues() {
+			cell := ec.m_Worksheet.Cell(cellName(row, first_col+i))
+			written, err := ec.setCellValue(cell, convertDataValue(typeNames[i], v))
+			if err != nil {
+				return CellRange{}, ec.newError("WriteDataReader", cellName(row, first_col+i), err)
+			}
+			format := formats[i]
+			if format == "" || ec.keepsDateFormat(cell, first_col+i, written) {
+				format = ec.formatFor(cell, first_col+i, written)
+			}
+			if written != nil && format != "" {
+				ec.applyStyle(cell, Style{NumberFormat: format})
+			}
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return CellRange{}, ec.newError("WriteDataReader", cellName(row, first_col), err)
+	}
+
+	output := NewCellRange(first_row, first_col, row, last_col)
+	output.Sheet = ec.m_Worksheet.Name()
+	return output, nil
+}
+
+// FormatForDataType : number format for a column of GetDataTypeName, empty for the default format
+func FormatForDataType(typeName string) string {
+	switch strings.ToUpper(typeName) {
+	case "DATE":
+		return FormatDate
+	case "DATETIME", "DATETIME2", "SMALLDATETIME", "DATETIMEOFFSET", "TIMESTAMP", "TIMESTAMPTZ":
+		return FormatDateTime
+	case "TIME", "TIMETZ":
+		return FormatTime
+	case "DECIMAL", "NUMERIC", "MONEY", "SMALLMONEY":
+		return FormatNumber
+	case "INT", "INT2", "INT4", "INT8", "BIGINT", "SMALLINT", "TINYINT":
+		return "0"
+	}
+	return ""
+}
+
+// convertDataValue : columns scanned as text (DATETIME, NUMERIC ...) back to time.Time or float64
+func convertDataValue(typeName string, value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	switch FormatForDataType(typeName) {
+	case FormatDate, FormatDateTime:
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t
+		}
+	case FormatNumber, "0":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// dataTypeFormat : FormatForDataType with dates in the formats set by SetDateFormat
+func (ec *ExcelControl) dataTypeFormat(typeName string) string {
+	switch format := FormatForDataType(typeName); format {
+	case FormatDate:
+		return ec.dateFormat()
+	case FormatDateTime:
+		return ec.dateTimeFormat()
+	default:
+		return format
+	}
+}
@@ -0,0 +1,94 @@
+package excel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	gosql "github.com/churikawit/gosrc/sql"
+	"github.com/churikawit/gosrc/sql/sqltest"
+)
+
+func queryFake(t *testing.T, rows *sqltest.Rows) *gosql.DataReader {
+	fake := sqltest.New()
+	fake.ExpectQuery("select * from sales").WillReturnRows(rows)
+	conn := gosql.NewPostgresConnector2(fake.DSN())
+	conn.DriverName = sqltest.DriverName
+	conn.SetLogger(gosql.NopLogger())
+	if err := conn.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.CloseConnection)
+	reader := conn.Query("select * from sales")
+	if reader == nil {
+		t.Fatal("Query returned nil")
+	}
+	t.Cleanup(reader.Close)
+	return reader
+}
+
+func sqltestDateRows(day time.Time) *sqltest.Rows {
+	return sqltest.NewRows(sqltest.Column{Name: "doc_date", TypeName: "DATE"}).AddRow(day)
+}
+
+func TestWriteDataReader(t *testing.T) {
+	created := time.Date(2021, 6, 1, 13, 30, 0, 0, time.UTC)
+	reader := queryFake(t, sqltest.NewRows(
+		sqltest.Column{Name: "doc_no", TypeName: "VARCHAR"},
+		sqltest.Column{Name: "amount", TypeName: "DECIMAL"},
+		sqltest.Column{Name: "created", TypeName: "TIMESTAMP"},
+	).
+		AddRow("S001", 1234.5, created).
+		AddRow("S002", nil, nil))
+
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	rng, err := ec.WriteDataReader(reader, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rng.Sheet = ""; rng.String() != "B2:D4" {
+		t.Errorf("WriteDataReader range == %q, want B2:D4", rng.String())
+	}
+
+	got, err := ec.ReadRange("B2:D4")
+	want := [][]interface{}{
+		{"doc_no", "amount", "created"},
+		{"S001", 1234.5, created},
+		{"S002", nil, nil},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadRange(B2:D4) == %v, %v, want %v", got, err, want)
+	}
+
+	header := ec.m_Worksheet.Cell("B2").X().SAttr
+	if header == nil || *header != *ec.m_Worksheet.Cell("D2").X().SAttr {
+		t.Errorf("header cells do not share one style")
+	}
+	if n := len(ec.m_Styles); n != 3 {
+		t.Errorf("WriteDataReader created %d styles, want 3 (header, #,##0.00, date time)", n)
+	}
+}
+
+func TestFormatForDataType(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"DATE", "yyyy-mm-dd"},
+		{"datetime2", "yyyy-mm-dd hh:mm:ss"},
+		{"TIMESTAMPTZ", "yyyy-mm-dd hh:mm:ss"},
+		{"NUMERIC", "#,##0.00"},
+		{"BIGINT", "0"},
+		{"VARCHAR", ""},
+		{"FLOAT", ""},
+	}
+
+	for _, c := range cases {
+		got := FormatForDataType(c.in)
+		if got != c.want {
+			t.Errorf("FormatForDataType(%q) == %q, want %q", c.in, got, c.want)
+		}
+	}
+}
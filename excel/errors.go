@@ -0,0 +1,51 @@
+package excel
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNotOpen : returned when ExcelControl is used before OpenOrCreate
+var ErrNotOpen = errors.New("excel: no workbook is open")
+
+// Error : an ExcelControl operation failed, with the file, sheet and cell it was working on
+type Error struct {
+	Op    string // method name, e.g. "WriteRow"
+	File  string
+	Sheet string
+	Cell  string // A1 reference, empty if the operation is not about a cell
+	Err   error
+}
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+	sb.WriteString("excel: ")
+	sb.WriteString(e.Op)
+	if e.File != "" {
+		sb.WriteString(" " + e.File)
+	}
+	switch {
+	case e.Sheet != "" && e.Cell != "":
+		sb.WriteString(" " + e.Sheet + "!" + e.Cell)
+	case e.Sheet != "":
+		sb.WriteString(" sheet " + e.Sheet)
+	case e.Cell != "":
+		sb.WriteString(" " + e.Cell)
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError : wrap err with the file and active sheet of ec
+func (ec *ExcelControl) newError(op string, cell string, err error) error {
+	e := &Error{Op: op, File: ec.m_OpenFilename, Cell: cell, Err: err}
+	if ec.m_Worksheet != nil {
+		e.Sheet = ec.m_Worksheet.Name()
+	}
+	return e
+}
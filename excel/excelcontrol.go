@@ -1,6 +1,7 @@
 package excel
 
 import (
+	"errors"
 	"fmt"
 	_ "fmt"
 	_ "log"
@@ -8,8 +9,8 @@ import (
 	"os"
 	"runtime"
 	"strings"
-	"time"
 	_ "time"
+	"unicode/utf8"
 
 	"github.com/unidoc/unioffice/common/license"
 	"github.com/unidoc/unioffice/spreadsheet"
@@ -31,81 +32,145 @@ func init() {
 // -----------------------------------------------------------------------------------------------------------
 
 type ExcelControl struct {
-	m_ExcelApp     int
-	m_OpenFile     bool
-	m_OpenFilename string
-	m_Workbook     *spreadsheet.Workbook
-	m_Worksheet    *spreadsheet.Sheet
+	m_ExcelApp       int
+	m_OpenFile       bool
+	m_OpenFilename   string
+	m_Workbook       *spreadsheet.Workbook
+	m_Worksheet      *spreadsheet.Sheet
+	m_Styles         map[string]spreadsheet.CellStyle // styles created for this workbook, by key
+	m_StyleDefs      map[uint32]styleDef              // definition of each style in m_Styles, by style index
+	m_HeaderStyle    Style
+	m_DateFormat     string
+	m_DateTimeFormat string
+	m_ColumnFormats  map[string]map[int]string // sheet name (lower case) -> column -> format
+	m_NilText        string
+	m_Unsupported    func(value interface{}) (interface{}, error)
 }
 
 func New() *ExcelControl {
-	output := &ExcelControl{m_ExcelApp: 1, m_OpenFile: false, m_OpenFilename: ""}
+	output := &ExcelControl{m_ExcelApp: 1, m_OpenFile: false, m_OpenFilename: "", m_HeaderStyle: Style{Bold: true},
+		m_DateFormat: FormatDate, m_DateTimeFormat: FormatDateTime}
 	return output
 }
 
-// RowCol : row = [1,n], column = [1,256]
-func RowCol(row, column int) (output string) {
-	output = ""
+// MaxRow, MaxColumn : largest row and column number of a worksheet
+const (
+	MaxRow    = 1048576
+	MaxColumn = 16384 // XFD
+)
+
+// RowCol : row = [1,n], column = [1,16384], panic if column is invalid, kept for old callers, see FormatCellRef
+func RowCol(row, column int) string {
 	if column <= 0 {
 		panic(fmt.Sprintf("RowCol : invalid column:%d\n", column))
 	}
+	return columnLetters(column) + fmt.Sprintf("%d", row)
+}
 
-	var o, r int
-	var temp int = column - 1
-	var c byte = byte('A')
+// FormatCellRef : row = [1,1048576], column = [1,16384] to "A1" reference
+func FormatCellRef(row, column int) (string, error) {
+	if row <= 0 || row > MaxRow {
+		return "", fmt.Errorf("invalid row %d, must be in [1,%d]", row, MaxRow)
+	}
+	if column <= 0 || column > MaxColumn {
+		return "", fmt.Errorf("invalid column %d, must be in [1,%d]", column, MaxColumn)
+	}
+	return columnLetters(column) + fmt.Sprintf("%d", row), nil
+}
 
-	for temp >= 0 {
-		o = temp / 26
-		r = temp % 26
+// cellName : FormatCellRef for messages and references already checked, "R0C5" when out of range
+func cellName(row, column int) string {
+	if ref, err := FormatCellRef(row, column); err == nil {
+		return ref
+	}
+	return fmt.Sprintf("R%dC%d", row, column)
+}
 
-		output = string(c+byte(r)) + output
-		temp = o - 1
+// columnLetters : column = [1,n] to "A", "B" .. "Z", "AA" ..
+func columnLetters(column int) string {
+	output := ""
+	for temp := column - 1; temp >= 0; temp = temp/26 - 1 {
+		output = string(byte('A')+byte(temp%26)) + output
 	}
-	output = output + fmt.Sprintf("%d", row)
-	return
+	return output
 }
 
-func (ec *ExcelControl) OpenOrCreate(filename string) {
+// OpenOrCreate : open filename if it exists, otherwise start a new workbook that will be saved there
+func (ec *ExcelControl) OpenOrCreate(filename string) error {
 	if IsFileExists(filename) {
-		ec.open(filename)
-	} else {
-		CreateDirectory(filename)
-		ec.create(filename)
+		return ec.open(filename)
 	}
+	return ec.create(filename)
 }
 
-func (ec *ExcelControl) create(filename string) {
+func (ec *ExcelControl) create(filename string) error {
 	ec.m_OpenFilename = filename
 	ec.m_Workbook = spreadsheet.New()
 	sheet := ec.m_Workbook.AddSheet()
 	ec.m_Worksheet = &sheet
 	ec.m_Worksheet.Cell("A1").SetString("") // create empty cell
+	ec.m_Styles = map[string]spreadsheet.CellStyle{}
+	ec.m_StyleDefs = map[uint32]styleDef{}
+	ec.m_OpenFile = true
+	return nil
 }
 
-func (ec *ExcelControl) open(filename string) {
+func (ec *ExcelControl) open(filename string) error {
 	ec.m_OpenFilename = filename
-	var err error
-	ec.m_Workbook, err = spreadsheet.Open(filename)
+	ec.m_OpenFile = false
+	ec.m_Workbook = nil
+	ec.m_Worksheet = nil
+
+	wb, err := spreadsheet.Open(filename)
 	if err != nil {
-		fmt.Printf("%#v\n", err)
-		return
+		return ec.newError("Open", "", err)
+	}
+	if wb.SheetCount() == 0 {
+		wb.AddSheet()
 	}
+	ec.m_Workbook = wb
 	ec.m_Worksheet = ec.GetSheet(1)
+	ec.m_Styles = map[string]spreadsheet.CellStyle{}
+	ec.m_StyleDefs = map[uint32]styleDef{}
+	ec.m_OpenFile = true
+	return nil
+}
+
+// Save : save to the file given to OpenOrCreate
+func (ec *ExcelControl) Save() error {
+	return ec.SaveAs(ec.m_OpenFilename)
 }
 
-func (ec *ExcelControl) SaveAs(filename string) {
-	ec.m_Workbook.SaveToFile(filename)
+// SaveAs : save the workbook, create the directory of filename if needed
+func (ec *ExcelControl) SaveAs(filename string) error {
+	if ec.m_Workbook == nil {
+		return ec.newError("SaveAs", "", ErrNotOpen)
+	}
+	if err := createDirectory(filename); err != nil {
+		return &Error{Op: "SaveAs", File: filename, Err: err}
+	}
+	if err := ec.m_Workbook.SaveToFile(filename); err != nil {
+		return &Error{Op: "SaveAs", File: filename, Err: err}
+	}
+	return nil
 }
 
 func (ec *ExcelControl) CountSheet() int {
+	if ec.m_Workbook == nil {
+		return 0
+	}
 	return ec.m_Workbook.SheetCount()
 }
 
 // RemoveSheet : sheet_no [1,n], can remove only last sheet
-func (ec *ExcelControl) RemoveSheet(sheet_no int) {
-	if 0 < sheet_no && sheet_no <= ec.CountSheet() {
-		ec.m_Workbook.RemoveSheet(sheet_no - 1)
+func (ec *ExcelControl) RemoveSheet(sheet_no int) error {
+	if err := ec.checkSheetNo("RemoveSheet", sheet_no); err != nil {
+		return err
+	}
+	if err := ec.m_Workbook.RemoveSheet(sheet_no - 1); err != nil {
+		return ec.newError("RemoveSheet", "", err)
 	}
+	return nil
 }
 
 func (ec *ExcelControl) GetSheet(sheet_no int) *spreadsheet.Sheet {
@@ -123,23 +188,69 @@ func (ec *ExcelControl) GetSheetName(sheet_no int) string {
 	return ""
 }
 
+// GetSheetByName : nil if the workbook has no sheet named sheetname (case-insensitive)
+func (ec *ExcelControl) GetSheetByName(sheetname string) *spreadsheet.Sheet {
+	for i := 1; i <= ec.CountSheet(); i++ {
+		if strings.EqualFold(ec.GetSheetName(i), sheetname) {
+			return ec.GetSheet(i)
+		}
+	}
+	return nil
+}
+
 // SetSheetName: sheet_no = [1,n], sheetname must differ from the other sheets
-func (ec *ExcelControl) SetSheetName(sheetname string, sheet_no int) {
-	ss := ec.GetSheet(sheet_no)
-	if ss != nil {
-		ss.SetName(sheetname)
+func (ec *ExcelControl) SetSheetName(sheetname string, sheet_no int) error {
+	if err := ec.checkSheetNo("SetSheetName", sheet_no); err != nil {
+		return err
 	}
+	if err := checkSheetName(sheetname); err != nil {
+		return ec.newError("SetSheetName", "", err)
+	}
+	for i := 1; i <= ec.CountSheet(); i++ {
+		if i != sheet_no && strings.EqualFold(ec.GetSheetName(i), sheetname) {
+			return ec.newError("SetSheetName", "", fmt.Errorf("sheet name %q is already used by sheet %d", sheetname, i))
+		}
+	}
+	ec.GetSheet(sheet_no).SetName(sheetname)
+	return nil
 }
 
-// SetActiveSheet: sheet_no = [1,n]
-func (ec *ExcelControl) SetActiveSheet(sheet_no int) {
-	var count int = ec.CountSheet()
-	if count <= 0 {
-		return
+// checkSheetName : Excel limits sheet names to 31 characters without : \ / ? * [ ]
+func checkSheetName(sheetname string) error {
+	if strings.TrimSpace(sheetname) == "" {
+		return errors.New("sheet name is empty")
+	}
+	if utf8.RuneCountInString(sheetname) > 31 {
+		return fmt.Errorf("sheet name %q is longer than 31 characters", sheetname)
+	}
+	if i := strings.IndexAny(sheetname, `:\/?*[]`); i >= 0 {
+		return fmt.Errorf("sheet name %q contains %q", sheetname, sheetname[i])
+	}
+	if strings.HasPrefix(sheetname, "'") || strings.HasSuffix(sheetname, "'") {
+		return fmt.Errorf("sheet name %q starts or ends with an apostrophe", sheetname)
+	}
+	return nil
+}
+
+func (ec *ExcelControl) checkSheetNo(op string, sheet_no int) error {
+	if ec.m_Workbook == nil {
+		return ec.newError(op, "", ErrNotOpen)
+	}
+	if sheet_no <= 0 || sheet_no > ec.CountSheet() {
+		return ec.newError(op, "", fmt.Errorf("invalid sheet number %d, workbook has %d sheets", sheet_no, ec.CountSheet()))
+	}
+	return nil
+}
+
+// SetActiveSheet: sheet_no = [1,n], add sheets if the workbook has less than sheet_no sheets
+func (ec *ExcelControl) SetActiveSheet(sheet_no int) error {
+	if ec.m_Workbook == nil {
+		return ec.newError("SetActiveSheet", "", ErrNotOpen)
 	}
 	if sheet_no <= 0 {
-		sheet_no = 1
+		return ec.newError("SetActiveSheet", "", fmt.Errorf("invalid sheet number %d", sheet_no))
 	}
+	var count int = ec.CountSheet()
 	if sheet_no > count {
 		for j := count; j < sheet_no; j++ {
 			ec.m_Workbook.AddSheet()
@@ -147,77 +258,98 @@ func (ec *ExcelControl) SetActiveSheet(sheet_no int) {
 	}
 
 	ec.m_Worksheet = &(ec.m_Workbook.Sheets()[sheet_no-1])
+	return nil
 }
 
 func (ec *ExcelControl) SetPassword() {
 
 }
 
+// worksheet : active sheet, error if no workbook is open
+func (ec *ExcelControl) worksheet(op string) (*spreadsheet.Sheet, error) {
+	if ec.m_Workbook == nil || ec.m_Worksheet == nil {
+		return nil, ec.newError(op, "", ErrNotOpen)
+	}
+	return ec.m_Worksheet, nil
+}
+
+// cellRef : worksheet and A1 reference of (row, column), error carries the op and position
+func (ec *ExcelControl) cellRef(op string, row, column int) (*spreadsheet.Sheet, string, error) {
+	sheet, err := ec.worksheet(op)
+	if err != nil {
+		return nil, "", err
+	}
+	cellID, err := FormatCellRef(row, column)
+	if err != nil {
+		return nil, "", ec.newError(op, fmt.Sprintf("R%dC%d", row, column), err)
+	}
+	return sheet, cellID, nil
+}
+
 // =================================== Data ==========================================
 
 // WriteHeader: first_row = [1,n], first_col = [1,n]
-func (ec *ExcelControl) WriteHeader(header []string, first_row int, first_col int) {
+func (ec *ExcelControl) WriteHeader(header []string, first_row int, first_col int) error {
 	l := len(header)
 
-	if first_row <= 0 || first_col <= 0 {
-		return
-	}
 	for i := 0; i < l; i++ {
-		cellID := RowCol(first_row, first_col+i)
-		ec.m_Worksheet.Cell(cellID).SetString(header[i])
+		sheet, cellID, err := ec.cellRef("WriteHeader", first_row, first_col+i)
+		if err != nil {
+			return err
+		}
+		sheet.Cell(cellID).SetString(header[i])
 	}
+	return nil
 }
 
 // WriteRow: first_row = [1,n], first_col = [1,n]
-func (ec *ExcelControl) WriteRow(datarow []interface{}, first_row int, first_col int) {
+// values may be any bool, number, string, []byte, time.Time, pointer, sql.Null* or fmt.Stringer,
+// nil is written as set by SetNilText, other types go to SetUnsupportedTypeFunc or fail with ErrUnsupportedType,
+// integers beyond 2^53 are written as text because a number cell would round them
+// values get the format of their column (SetColumnFormat), dates the format set by SetDateFormat
+// unless the cell of an opened workbook already shows dates
+func (ec *ExcelControl) WriteRow(datarow []interface{}, first_row int, first_col int) error {
 	len := len(datarow)
 
-	if first_row <= 0 || first_col <= 0 {
-		return
-	}
 	for i := 0; i < len; i++ {
-		cellID := RowCol(first_row, first_col+i)
-		switch s2 := (datarow[i]).(type) {
-		case bool:
-			ec.m_Worksheet.Cell(cellID).SetBool(s2)
-			// fmt.Printf("%d:bool\n", i+1)
-		case time.Time:
-			ec.m_Worksheet.Cell(cellID).SetTime(s2)
-			// fmt.Printf("%d:time\n", i+1)
-		case string:
-			ec.m_Worksheet.Cell(cellID).SetString(s2)
-			// fmt.Printf("%d:string\n", i+1)
-		case int32:
-			ec.m_Worksheet.Cell(cellID).SetNumber(float64(s2))
-			// fmt.Printf("%d:int32\n", i+1)
-		case int64:
-			ec.m_Worksheet.Cell(cellID).SetNumber(float64(s2))
-			// fmt.Printf("%d:int64\n", i+1)
-		case float64:
-			ec.m_Worksheet.Cell(cellID).SetNumber(s2)
-			// fmt.Printf("%d:float64\n", i+1)
-		default:
-			ec.m_Worksheet.Cell(cellID).SetString("")
+		sheet, cellID, err := ec.cellRef("WriteRow", first_row, first_col+i)
+		if err != nil {
+			return err
+		}
+		cell := sheet.Cell(cellID)
+		v, err := ec.setCellValue(cell, datarow[i])
+		if err != nil {
+			return ec.newError("WriteRow", cellID, err)
+		}
+		if format := ec.formatFor(cell, first_col+i, v); format != "" {
+			ec.applyStyle(cell, Style{NumberFormat: format})
 		}
-
 	}
+	return nil
 }
 
-func (ec *ExcelControl) WriteString(txt string, first_row int, first_col int) {
+func (ec *ExcelControl) WriteString(txt string, first_row int, first_col int) error {
 	if strings.Index(txt, "=") >= 0 {
-		ec.WriteFormula(txt, first_row, first_col)
-	} else {
-		cellID := RowCol(first_row, first_col)
-		ec.m_Worksheet.Cell(cellID).SetString(txt)
+		return ec.WriteFormula(txt, first_row, first_col)
 	}
+	sheet, cellID, err := ec.cellRef("WriteString", first_row, first_col)
+	if err != nil {
+		return err
+	}
+	sheet.Cell(cellID).SetString(txt)
+	return nil
 }
-func (ec *ExcelControl) WriteFormula(txt string, first_row, first_col int) {
+func (ec *ExcelControl) WriteFormula(txt string, first_row, first_col int) error {
 	index := strings.LastIndex(txt, "=")
 	if index >= 0 {
 		txt = txt[index+1:]
 	}
-	cellID := RowCol(first_row, first_col)
-	ec.m_Worksheet.Cell(cellID).SetFormulaRaw(txt)
+	sheet, cellID, err := ec.cellRef("WriteFormula", first_row, first_col)
+	if err != nil {
+		return err
+	}
+	sheet.Cell(cellID).SetFormulaRaw(txt)
+	return nil
 }
 
 // =================================== Util ==========================================
@@ -241,6 +373,15 @@ func CreateDirectory(file_path string) bool {
 	return false
 }
 
+// createDirectory : create the directory of file_path, nil if file_path has no directory
+func createDirectory(file_path string) error {
+	index := strings.LastIndexByte(file_path, PATH_SEPARATOR)
+	if index <= 0 {
+		return nil
+	}
+	return os.MkdirAll(file_path[0:index], 0755)
+}
+
 func IsFileExists(path string) bool {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return false
@@ -1,6 +1,10 @@
 package excel
 
-import "testing"
+import (
+	"errors"
+	"testing"
+	"time"
+)
 
 func TestGetSheetName(t *testing.T) {
 	cases := []struct {
@@ -13,10 +17,14 @@ func TestGetSheetName(t *testing.T) {
 
 	ec := New()
 	filename := "test.xlsx"
-	ec.OpenOrCreate(filename)
+	if err := ec.OpenOrCreate(filename); err != nil {
+		t.Fatal(err)
+	}
 
 	for _, c := range cases {
-		ec.SetSheetName(c.in, 1)
+		if err := ec.SetSheetName(c.in, 1); err != nil {
+			t.Errorf("SetSheetName(%q) error %v", c.in, err)
+		}
 		got := ec.GetSheetName(1)
 		if got != c.want {
 			t.Errorf("GetSheetName(%q) == %q, want %q", c.in, got, c.want)
@@ -24,20 +32,90 @@ func TestGetSheetName(t *testing.T) {
 	}
 }
 
+func TestSetSheetNameError(t *testing.T) {
+	cases := []string{
+		"",
+		"Sales/2021",
+		"[draft]",
+		"a name that is longer than thirty one",
+		"Summary", // used by sheet 2
+	}
+
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetActiveSheet(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetSheetName("Summary", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		if err := ec.SetSheetName(c, 1); err == nil {
+			t.Errorf("SetSheetName(%q) == nil, want error", c)
+		}
+	}
+	if err := ec.SetSheetName("Data", 3); err == nil {
+		t.Errorf("SetSheetName(%q, 3) == nil, want error", "Data")
+	}
+}
+
 func TestSetActiveSheet(t *testing.T) {
 	ec := New()
 	filename := "test.xlsx"
-	ec.OpenOrCreate(filename)
+	if err := ec.OpenOrCreate(filename); err != nil {
+		t.Fatal(err)
+	}
 
 	want := 10
 	in := 10
-	ec.SetActiveSheet(in)
+	if err := ec.SetActiveSheet(in); err != nil {
+		t.Fatal(err)
+	}
 	got := ec.CountSheet()
 	if got != want {
 		t.Errorf("SetActiveSheet(%d) == %d, want %d", in, got, want)
 	}
 }
 
+func TestNotOpen(t *testing.T) {
+	ec := New()
+	errs := []error{
+		ec.SaveAs("test.xlsx"),
+		ec.SetSheetName("Sheet1", 1),
+		ec.SetActiveSheet(1),
+		ec.WriteHeader([]string{"a"}, 1, 1),
+		ec.WriteRow([]interface{}{1.5}, 1, 1),
+		ec.WriteString("a", 1, 1),
+	}
+	for i, err := range errs {
+		if !errors.Is(err, ErrNotOpen) {
+			t.Errorf("call %d == %v, want ErrNotOpen", i, err)
+		}
+	}
+}
+
+func TestWriteRowError(t *testing.T) {
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetSheetName("Data", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ec.WriteRow([]interface{}{1.5, 2.5}, 1, MaxColumn)
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("WriteRow at last column == %v, want *Error", err)
+	}
+	if e.Op != "WriteRow" || e.File != "test.xlsx" || e.Sheet != "Data" || e.Cell != "R1C16385" {
+		t.Errorf("WriteRow at last column == %+v", e)
+	}
+}
+
 func TestRowCol(t *testing.T) {
 	cases := []struct {
 		in_row int
@@ -58,3 +136,49 @@ func TestRowCol(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatCellRef(t *testing.T) {
+	cases := []struct {
+		in_row  int
+		in_col  int
+		want    string
+		wantErr bool
+	}{
+		{1, 1, "A1", false},
+		{1048576, 16384, "XFD1048576", false},
+		{0, 1, "", true},
+		{1, 0, "", true},
+		{1048577, 1, "", true},
+		{1, 16385, "", true},
+	}
+
+	for _, c := range cases {
+		got, err := FormatCellRef(c.in_row, c.in_col)
+		if got != c.want || (err != nil) != c.wantErr {
+			t.Errorf("FormatCellRef(%d,%d) == %q, %v, want %q", c.in_row, c.in_col, got, err, c.want)
+		}
+	}
+}
+
+func TestInvalidCellErrors(t *testing.T) {
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ec.ReadCellRange(CellRange{Sheet: "Missing"}); err == nil {
+		t.Errorf("ReadCellRange(CellRange{Sheet: Missing}) == nil error, want error")
+	}
+	if err := ec.SetCellRangeStyle(CellRange{Sheet: "Missing"}, Style{Bold: true}); err == nil {
+		t.Errorf("SetCellRangeStyle(CellRange{Sheet: Missing}) == nil error, want error")
+	}
+	reader := queryFake(t, sqltestDateRows(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)))
+	if _, err := ec.WriteDataReader(reader, 1, 0); err == nil {
+		t.Errorf("WriteDataReader at column 0 == nil error, want error")
+	}
+	var uploads []*testUpload
+	_, err := ec.ReadStructs(&uploads, 0)
+	var e *Error
+	if !errors.As(err, &e) || e.Cell != "R0C1" {
+		t.Errorf("ReadStructs at row 0 == %v, want an error at R0C1", err)
+	}
+}
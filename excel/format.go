@@ -0,0 +1,176 @@
+package excel
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unioffice/spreadsheet"
+)
+
+// number formats, any other Excel format code can be used as well
+const (
+	FormatGeneral  = "General"
+	FormatInteger  = "#,##0"
+	FormatNumber   = "#,##0.00"
+	FormatPercent  = "0%"
+	FormatPercent2 = "0.00%"
+	FormatDate     = "yyyy-mm-dd"
+	FormatDateTime = "yyyy-mm-dd hh:mm:ss"
+	FormatTime     = "hh:mm:ss"
+
+	// Thai Buddhist era, 2021-06-01 shows as 01/06/2564
+	FormatThaiDate     = "[$-th-TH]dd/mm/bbbb"
+	FormatThaiDateTime = "[$-th-TH]dd/mm/bbbb hh:mm:ss"
+	FormatThaiLongDate = "[$-th-TH]d mmmm bbbb" // 1 มิถุนายน 2564
+)
+
+// namedFormats : names accepted wherever a format is, case-insensitive
+var namedFormats = map[string]string{
+	"general":        FormatGeneral,
+	"integer":        FormatInteger,
+	"number":         FormatNumber,
+	"percent":        FormatPercent,
+	"percent2":       FormatPercent2,
+	"date":           FormatDate,
+	"datetime":       FormatDateTime,
+	"time":           FormatTime,
+	"thai-date":      FormatThaiDate,
+	"thai-datetime":  FormatThaiDateTime,
+	"thai-long-date": FormatThaiLongDate,
+}
+
+// resolveFormat : format code of a named format, other formats are returned as they are
+func resolveFormat(format string) string {
+	if code, ok := namedFormats[strings.ToLower(strings.TrimSpace(format))]; ok {
+		return code
+	}
+	return format
+}
+
+// SetDateFormat : formats of time.Time values written by WriteRow, WriteStructs and WriteDataReader
+// without a column format, date for values at midnight and datetime for the others,
+// empty keeps the current format, defaults are FormatDate and FormatDateTime
+func (ec *ExcelControl) SetDateFormat(date, datetime string) {
+	if date != "" {
+		ec.m_DateFormat = resolveFormat(date)
+	}
+	if datetime != "" {
+		ec.m_DateTimeFormat = resolveFormat(datetime)
+	}
+}
+
+// SetBuddhistEra : show dates in the Thai Buddhist era (FormatThaiDate, FormatThaiDateTime) or back to the defaults
+func (ec *ExcelControl) SetBuddhistEra(on bool) {
+	if on {
+		ec.SetDateFormat(FormatThaiDate, FormatThaiDateTime)
+	} else {
+		ec.SetDateFormat(FormatDate, FormatDateTime)
+	}
+}
+
+// SetColumnFormat : col = [1,16384] of the active sheet, format for every value the row-writing helpers
+// put in this column, a format name (see namedFormats) or code, empty removes it
+func (ec *ExcelControl) SetColumnFormat(col int, format string) error {
+	sheet, _, err := ec.cellRef("SetColumnFormat", 1, col)
+	if err != nil {
+		return err
+	}
+	key := strings.ToLower(sheet.Name())
+	if format == "" {
+		delete(ec.m_ColumnFormats[key], col)
+		return nil
+	}
+	if ec.m_ColumnFormats == nil {
+		ec.m_ColumnFormats = map[string]map[int]string{}
+	}
+	if ec.m_ColumnFormats[key] == nil {
+		ec.m_ColumnFormats[key] = map[int]string{}
+	}
+	ec.m_ColumnFormats[key][col] = resolveFormat(format)
+	return nil
+}
+
+// SetCellFormat : row = [1,n], col = [1,n] of the active sheet, keeps the rest of the cell style
+func (ec *ExcelControl) SetCellFormat(row, col int, format string) error {
+	if format == "" {
+		return ec.newError("SetCellFormat", cellName(row, col), fmt.Errorf("empty format"))
+	}
+	return ec.SetCellStyle(row, col, Style{NumberFormat: format})
+}
+
+// SetRangeFormat : SetCellFormat on every cell of "A1:D20", see SetRangeStyle
+func (ec *ExcelControl) SetRangeFormat(ref string, format string) error {
+	if format == "" {
+		return ec.newError("SetRangeFormat", ref, fmt.Errorf("empty format"))
+	}
+	return ec.SetRangeStyle(ref, Style{NumberFormat: format})
+}
+
+func (ec *ExcelControl) columnFormat(col int) string {
+	if ec.m_Worksheet == nil {
+		return ""
+	}
+	return ec.m_ColumnFormats[strings.ToLower(ec.m_Worksheet.Name())][col]
+}
+
+// formatFor : format of a value written to column col, empty if the cell keeps its format,
+// values other than dates reset a date format ExcelControl gave the cell to General
+func (ec *ExcelControl) formatFor(cell spreadsheet.Cell, col int, value interface{}) string {
+	if value == nil || ec.keepsDateFormat(cell, col, value) {
+		return ""
+	}
+	if f := ec.columnFormat(col); f != "" {
+		return f
+	}
+	if t, ok := value.(time.Time); ok {
+		if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+			return ec.dateFormat()
+		}
+		return ec.dateTimeFormat()
+	}
+	if ec.hasDateFormat(cell) {
+		return FormatGeneral // a number written over a date does not show as a date
+	}
+	return ""
+}
+
+// hasDateFormat : cell has a date format ExcelControl set, formats of opened workbooks do not count
+func (ec *ExcelControl) hasDateFormat(cell spreadsheet.Cell) bool {
+	x := cell.X()
+	if x == nil || x.SAttr == nil {
+		return false
+	}
+	def, ok := ec.m_StyleDefs[*x.SAttr]
+	return ok && isDateFormat(def.style.NumberFormat)
+}
+
+// keepsDateFormat : a date written to a cell of an opened workbook that already shows dates keeps that format,
+// unless the column has a format
+func (ec *ExcelControl) keepsDateFormat(cell spreadsheet.Cell, col int, value interface{}) bool {
+	if _, ok := value.(time.Time); !ok || ec.columnFormat(col) != "" {
+		return false
+	}
+	x := cell.X()
+	if x == nil || x.SAttr == nil {
+		return false
+	}
+	if def, ok := ec.m_StyleDefs[*x.SAttr]; ok && def.style.NumberFormat != "" {
+		return false // a format ExcelControl set, replaced as before
+	}
+	return ec.isDateCell(cell)
+}
+
+func (ec *ExcelControl) dateFormat() string {
+	if ec.m_DateFormat == "" {
+		return FormatDate
+	}
+	return ec.m_DateFormat
+}
+
+func (ec *ExcelControl) dateTimeFormat() string {
+	if ec.m_DateTimeFormat == "" {
+		return FormatDateTime
+	}
+	return ec.m_DateTimeFormat
+}
@@ -0,0 +1,189 @@
+package excel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/unidoc/unioffice/schema/soo/sml"
+)
+
+func TestWriteRowFormats(t *testing.T) {
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2021, 6, 1, 13, 30, 0, 0, time.UTC)
+
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetColumnFormat(2, "number"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetColumnFormat(3, "0.0%"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetRangeStyle("A1:D1", Style{FillColor: "EEEEEE"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.WriteRow([]interface{}{day, 1234.5, 0.25, at}, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ec.SetBuddhistEra(true)
+	if err := ec.WriteRow([]interface{}{day, nil, "n/a", at}, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// numbers over dates ExcelControl formatted, a number format set on the cell stays
+	if err := ec.WriteRow([]interface{}{day, nil, nil, at}, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetCellFormat(3, 5, "integer"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.WriteRow([]interface{}{42.0, nil, nil, "n/a", 7.0}, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ref  string
+		want Style
+	}{
+		{"A1", Style{FillColor: "EEEEEE", NumberFormat: FormatDate}},
+		{"B1", Style{FillColor: "EEEEEE", NumberFormat: FormatNumber}},
+		{"C1", Style{FillColor: "EEEEEE", NumberFormat: "0.0%"}},
+		{"D1", Style{FillColor: "EEEEEE", NumberFormat: FormatDateTime}},
+		{"A2", Style{NumberFormat: FormatThaiDate}},
+		{"B2", Style{}},
+		{"C2", Style{NumberFormat: "0.0%"}},
+		{"D2", Style{NumberFormat: FormatThaiDateTime}},
+		{"A3", Style{NumberFormat: FormatGeneral}},
+		{"D3", Style{NumberFormat: FormatGeneral}},
+		{"E3", Style{NumberFormat: FormatInteger}},
+	}
+	for _, c := range cases {
+		if got := styleAt(t, ec, c.ref); got != c.want {
+			t.Errorf("style of %s == %+v, want %+v", c.ref, got, c.want)
+		}
+	}
+
+	if v, err := ec.ReadCell(2, 1); err != nil || v != day {
+		t.Errorf("ReadCell(2,1) with Buddhist era format == %v, %v, want %v", v, err, day)
+	}
+	if v, err := ec.ReadCell(3, 1); err != nil || v != 42.0 {
+		t.Errorf("ReadCell(3,1) of a number written over a date == %v, %v, want 42", v, err)
+	}
+}
+
+func TestSetCellFormat(t *testing.T) {
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.WriteRow([]interface{}{0.5, 0.75}, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetCellFormat(1, 1, "percent"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetRangeFormat("B1:B1", FormatPercent); err != nil {
+		t.Fatal(err)
+	}
+	if len(ec.m_Styles) != 1 {
+		t.Errorf("named and literal percent format created %d styles, want 1", len(ec.m_Styles))
+	}
+	if err := ec.SetCellFormat(1, 1, ""); err == nil {
+		t.Errorf("SetCellFormat with empty format == nil, want error")
+	}
+}
+
+func TestWriteDataReaderFormats(t *testing.T) {
+	created := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	ec.SetDateFormat("dd/mm/yyyy", "")
+	if _, err := ec.WriteDataReader(queryFake(t, sqltestDateRows(created)), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := styleAt(t, ec, "A2"); got.NumberFormat != "dd/mm/yyyy" {
+		t.Errorf("DATE column format == %q, want %q", got.NumberFormat, "dd/mm/yyyy")
+	}
+
+	if err := ec.SetColumnFormat(3, "thai-long-date"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ec.WriteDataReader(queryFake(t, sqltestDateRows(created)), 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := styleAt(t, ec, "C2"); got.NumberFormat != FormatThaiLongDate {
+		t.Errorf("DATE column with column format == %q, want %q", got.NumberFormat, FormatThaiLongDate)
+	}
+}
+
+func TestWriteRowOnOpenedWorkbook(t *testing.T) {
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	ec := openTemplate(t, func(ec *ExcelControl) {
+		if err := ec.WriteRow([]interface{}{day, 1.0, "x", day}, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		styles := []Style{
+			{FontName: "Tahoma", NumberFormat: "[$-th-TH]d mmm bbbb"},
+			{Bold: true, Border: BorderThin, NumberFormat: "number"},
+			{FillColor: "DDEBF7"},
+			{FontName: "Tahoma"},
+		}
+		for i, s := range styles {
+			if err := ec.SetCellStyle(1, i+1, s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := ec.WriteRow([]interface{}{day}, 3, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := ec.SetCellStyle(3, 1, styles[0]); err != nil {
+			t.Fatal(err)
+		}
+	})
+	var template []sml.CT_Xf
+	for _, ref := range []string{"A1", "B1", "C1", "D1", "A3"} {
+		template = append(template, *xfAt(ec, ref))
+	}
+	if err := ec.SetColumnFormat(4, "yyyy-mm-dd"); err != nil {
+		t.Fatal(err)
+	}
+
+	written := time.Date(2021, 7, 15, 0, 0, 0, 0, time.UTC)
+	if err := ec.WriteRow([]interface{}{written, 12.5, written, written}, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	for i, ref := range []string{"A1", "B1"} {
+		if got := *xfAt(ec, ref); !reflect.DeepEqual(got, template[i]) {
+			t.Errorf("style of %s == %+v, want the template's %+v", ref, got, template[i])
+		}
+	}
+	if xf := xfAt(ec, "C1"); !reflect.DeepEqual(xf.FillIdAttr, template[2].FillIdAttr) || styleAt(t, ec, "C1").NumberFormat != FormatDate {
+		t.Errorf("C1 lost its fill or did not get the date format")
+	}
+	if xf := xfAt(ec, "D1"); !reflect.DeepEqual(xf.FontIdAttr, template[3].FontIdAttr) || styleAt(t, ec, "D1").NumberFormat != "yyyy-mm-dd" {
+		t.Errorf("D1 lost its font or did not get the column format")
+	}
+	for col := 1; col <= 4; col += 2 {
+		if v, err := ec.ReadCell(1, col); err != nil || v != written {
+			t.Errorf("ReadCell(1,%d) == %v, %v, want %v", col, v, err, written)
+		}
+	}
+
+	// a DATE column keeps the template's date format as well
+	if _, err := ec.WriteDataReader(queryFake(t, sqltestDateRows(written)), 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := *xfAt(ec, "A3"); !reflect.DeepEqual(got, template[4]) {
+		t.Errorf("style of A3 == %+v, want the template's %+v", got, template[4])
+	}
+	if v, err := ec.ReadCell(3, 1); err != nil || v != written {
+		t.Errorf("ReadCell(3,1) == %v, %v, want %v", v, err, written)
+	}
+}
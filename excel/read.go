@@ -0,0 +1,317 @@
+package excel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unidoc/unioffice/spreadsheet"
+)
+
+// =================================== Read ==========================================
+
+// ReadCell : row = [1,n], col = [1,n] of the active sheet
+// return nil (empty), string, float64, bool or time.Time (number with a date format),
+// a formula cell returns its last calculated result
+func (ec *ExcelControl) ReadCell(row, col int) (interface{}, error) {
+	sheet, cellID, err := ec.cellRef("ReadCell", row, col)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := findRow(sheet, row)
+	if !ok {
+		return nil, nil
+	}
+	for _, cell := range r.Cells() {
+		if cell.Reference() == cellID {
+			v, err := ec.cellValue(cell)
+			if err != nil {
+				return nil, ec.newError("ReadCell", cellID, err)
+			}
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// ReadFormula : formula of a cell without the leading "=", empty if the cell has no formula
+func (ec *ExcelControl) ReadFormula(row, col int) (string, error) {
+	sheet, cellID, err := ec.cellRef("ReadFormula", row, col)
+	if err != nil {
+		return "", err
+	}
+	r, ok := findRow(sheet, row)
+	if !ok {
+		return "", nil
+	}
+	for _, cell := range r.Cells() {
+		if cell.Reference() == cellID {
+			return cell.GetFormula(), nil
+		}
+	}
+	return "", nil
+}
+
+// ReadRow : row = [1,n], values from column A to the last non-empty cell, see ReadCell
+func (ec *ExcelControl) ReadRow(row int) ([]interface{}, error) {
+	sheet, _, err := ec.cellRef("ReadRow", row, 1)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := findRow(sheet, row)
+	if !ok {
+		return []interface{}{}, nil
+	}
+	return ec.rowValues("ReadRow", r, 1, MaxColumn)
+}
+
+// ReadRange : "A1:D20", "B2" or "'Sheet 1'!A1:D20", rows of values from top to bottom, empty cells are nil
+// a range without a sheet name reads the active sheet, see ParseCellRange
+// the result stops at the last used row and column, so "A:C" does not return a million rows
+func (ec *ExcelControl) ReadRange(ref string) ([][]interface{}, error) {
+	r, err := ParseCellRange(ref)
+	if err != nil {
+		return nil, ec.newError("ReadRange", ref, err)
+	}
+	return ec.ReadCellRange(r)
+}
+
+// ReadCellRange : ReadRange of a parsed range
+func (ec *ExcelControl) ReadCellRange(rng CellRange) ([][]interface{}, error) {
+	sheet, err := ec.worksheet("ReadRange")
+	if err != nil {
+		return nil, err
+	}
+	if rng.Sheet != "" {
+		if sheet = ec.GetSheetByName(rng.Sheet); sheet == nil {
+			return nil, ec.newError("ReadRange", rng.String(), fmt.Errorf("no sheet named %q", rng.Sheet))
+		}
+	}
+	used, ok := rng.Intersect(usedRange(sheet))
+	if !ok {
+		used = CellRange{FirstRow: rng.FirstRow, LastRow: rng.FirstRow - 1}
+	}
+
+	output := make([][]interface{}, used.RowCount())
+	for _, r := range sheet.Rows() {
+		n := int(r.RowNumber())
+		if !used.Contains(n, used.FirstCol) {
+			continue
+		}
+		values, err := ec.rowValues("ReadRange", r, rng.FirstCol, rng.LastCol)
+		if err != nil {
+			return nil, err
+		}
+		output[n-rng.FirstRow] = values
+	}
+	for i := range output {
+		row := make([]interface{}, used.LastCol-rng.FirstCol+1)
+		copy(row, output[i])
+		output[i] = row
+	}
+	return output, nil
+}
+
+// usedRange : from A1 to the last row and column that have a cell
+func usedRange(sheet *spreadsheet.Sheet) CellRange {
+	output := CellRange{FirstRow: 1, FirstCol: 1}
+	for _, r := range sheet.Rows() {
+		for _, cell := range r.Cells() {
+			row, col, err := ParseCellRef(cell.Reference())
+			if err != nil || cell.IsEmpty() {
+				continue
+			}
+			output.LastRow = maxInt(output.LastRow, row)
+			output.LastCol = maxInt(output.LastCol, col)
+		}
+	}
+	return output
+}
+
+// rowValues : values of columns [first_col, last_col] up to the last non-empty cell
+func (ec *ExcelControl) rowValues(op string, r spreadsheet.Row, first_col, last_col int) ([]interface{}, error) {
+	output := []interface{}{}
+	for _, cell := range r.Cells() {
+		_, col, err := ParseCellRef(cell.Reference())
+		if err != nil {
+			return nil, ec.newError(op, cell.Reference(), err)
+		}
+		if col < first_col || col > last_col {
+			continue
+		}
+		v, err := ec.cellValue(cell)
+		if err != nil {
+			return nil, ec.newError(op, cell.Reference(), err)
+		}
+		if v == nil {
+			continue
+		}
+		for len(output) < col-first_col {
+			output = append(output, nil)
+		}
+		output = append(output, v)
+	}
+	return output, nil
+}
+
+// findRow : row of the sheet without creating it
+func findRow(sheet *spreadsheet.Sheet, row int) (spreadsheet.Row, bool) {
+	for _, r := range sheet.Rows() {
+		if int(r.RowNumber()) == row {
+			return r, true
+		}
+	}
+	return spreadsheet.Row{}, false
+}
+
+func (ec *ExcelControl) cellValue(cell spreadsheet.Cell) (interface{}, error) {
+	switch {
+	case cell.IsEmpty():
+		return nil, nil
+	case cell.HasFormula() && cell.GetCachedFormulaResult() == "":
+		return nil, nil
+	case cell.IsBool():
+		return cell.GetValueAsBool()
+	case cell.IsNumber():
+		if ec.isDateCell(cell) {
+			return cell.GetValueAsTime()
+		}
+		return cell.GetValueAsNumber()
+	}
+	// strings, and error values such as #DIV/0! as text
+	return cell.GetString(), nil
+}
+
+// -----------------------------------------------------------------------------------------------------------
+
+// RowIterator : walk the non-empty rows of a sheet
+//
+//	it := ec.Rows(2)
+//	for it.Next() {
+//		values := it.Values()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type RowIterator struct {
+	ec     *ExcelControl
+	rows   []spreadsheet.Row
+	pos    int
+	row    int
+	values []interface{}
+	err    error
+}
+
+// Rows : iterator over the rows of the active sheet starting at first_row = [1,n]
+func (ec *ExcelControl) Rows(first_row int) *RowIterator {
+	it := &RowIterator{ec: ec}
+	sheet, err := ec.worksheet("Rows")
+	if err != nil {
+		it.err = err
+		return it
+	}
+	for _, r := range sheet.Rows() {
+		if int(r.RowNumber()) >= first_row {
+			it.rows = append(it.rows, r)
+		}
+	}
+	return it
+}
+
+// Next : move to the next row that has a value, false at the end or on error
+func (it *RowIterator) Next() bool {
+	for it.err == nil && it.pos < len(it.rows) {
+		r := it.rows[it.pos]
+		it.pos++
+		values, err := it.ec.rowValues("Rows", r, 1, MaxColumn)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if len(values) == 0 {
+			continue
+		}
+		it.row = int(r.RowNumber())
+		it.values = values
+		return true
+	}
+	return false
+}
+
+// Row : row number [1,n] of the current row
+func (it *RowIterator) Row() int {
+	return it.row
+}
+
+// Values : values of the current row from column A, see ReadRow
+func (it *RowIterator) Values() []interface{} {
+	return it.values
+}
+
+func (it *RowIterator) Err() error {
+	return it.err
+}
+
+// -----------------------------------------------------------------------------------------------------------
+
+// isDateCell : a number cell is a date when its number format shows days, months, years or times
+func (ec *ExcelControl) isDateCell(cell spreadsheet.Cell) bool {
+	x := cell.X()
+	if x == nil || x.SAttr == nil {
+		return false
+	}
+	id := ec.m_Workbook.StyleSheet.GetCellStyle(*x.SAttr).NumberFormat()
+	if isBuiltinDateFormat(id) {
+		return true
+	}
+	if numFmts := ec.m_Workbook.StyleSheet.X().NumFmts; numFmts != nil {
+		for _, nf := range numFmts.NumFmt {
+			if nf.NumFmtIdAttr == id {
+				return isDateFormat(nf.FormatCodeAttr)
+			}
+		}
+	}
+	return false
+}
+
+// isBuiltinDateFormat : built-in date and time formats, including the Thai locale ones
+func isBuiltinDateFormat(id uint32) bool {
+	return (14 <= id && id <= 22) || (27 <= id && id <= 36) || (45 <= id && id <= 47) ||
+		(50 <= id && id <= 58) || (71 <= id && id <= 81)
+}
+
+// isDateFormat : "yyyy-mm-dd", "[$-th-TH]d mmm bbbb", "[h]:mm" ... but not "0.00E+00" or "General"
+func isDateFormat(format string) bool {
+	f := strings.ToLower(format)
+	f = strings.Split(f, ";")[0]
+	f = strings.ReplaceAll(f, "general", "")
+
+	var sb strings.Builder
+	quoted := false
+	for i := 0; i < len(f); i++ {
+		c := f[i]
+		switch {
+		case quoted:
+			quoted = c != '"'
+		case c == '"':
+			quoted = true
+		case c == '\\' || c == '_' || c == '*':
+			i++
+		case c == '[':
+			j := strings.IndexByte(f[i:], ']')
+			if j < 0 {
+				j = len(f) - i
+			}
+			// keep elapsed time [h] [mm] [ss], skip colours and locales [Red] [$-th-TH]
+			if content := f[i+1 : i+j]; strings.Trim(content, "hms") == "" {
+				sb.WriteString(content)
+			}
+			i += j
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	f = sb.String()
+	if strings.ContainsAny(f, "dmyhs") || strings.Contains(f, "bb") {
+		return true
+	}
+	i := strings.IndexByte(f, 'e')
+	return i >= 0 && (i+1 == len(f) || (f[i+1] != '+' && f[i+1] != '-'))
+}
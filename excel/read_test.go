@@ -0,0 +1,149 @@
+package excel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newReadTestControl(t *testing.T) *ExcelControl {
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]interface{}{
+		{"Doc No", "Amount", "Paid"},
+		{"S001", 10.5, true},
+		{"S002", int64(7)},
+	}
+	for i, row := range rows {
+		if err := ec.WriteRow(row, i+1, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ec.WriteString("=SUM(B2:B3)", 4, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.WriteString("note", 6, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := ec.m_Workbook.StyleSheet.AddCellStyle()
+	cs.SetNumberFormat("yyyy-mm-dd")
+	cell := ec.m_Worksheet.Cell("E2")
+	cell.SetDate(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	cell.SetStyle(cs)
+	return ec
+}
+
+func TestReadCell(t *testing.T) {
+	cases := []struct {
+		row, col int
+		want     interface{}
+	}{
+		{1, 1, "Doc No"},
+		{2, 2, 10.5},
+		{2, 3, true},
+		{3, 2, 7.0},
+		{3, 3, nil},
+		{100, 1, nil},
+		{2, 5, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	ec := newReadTestControl(t)
+	for _, c := range cases {
+		got, err := ec.ReadCell(c.row, c.col)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ReadCell(%d,%d) == %#v, %v, want %#v", c.row, c.col, got, err, c.want)
+		}
+	}
+
+	formula, err := ec.ReadFormula(4, 2)
+	if err != nil || formula != "SUM(B2:B3)" {
+		t.Errorf("ReadFormula(4,2) == %q, %v, want %q", formula, err, "SUM(B2:B3)")
+	}
+	if _, err := ec.ReadCell(0, 1); err == nil {
+		t.Errorf("ReadCell(0,1) == nil error, want error")
+	}
+}
+
+func TestReadRowAndRange(t *testing.T) {
+	ec := newReadTestControl(t)
+
+	got, err := ec.ReadRow(3)
+	if want := []interface{}{"S002", 7.0}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadRow(3) == %v, %v, want %v", got, err, want)
+	}
+	got, err = ec.ReadRow(6)
+	if want := []interface{}{nil, nil, nil, "note"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadRow(6) == %v, %v, want %v", got, err, want)
+	}
+
+	rng, err := ec.ReadRange("B2:C3")
+	if want := [][]interface{}{{10.5, true}, {7.0, nil}}; err != nil || !reflect.DeepEqual(rng, want) {
+		t.Errorf("ReadRange(B2:C3) == %v, %v, want %v", rng, err, want)
+	}
+	if _, err := ec.ReadRange("B2:C"); err == nil {
+		t.Errorf("ReadRange(B2:C) == nil error, want error")
+	}
+}
+
+func TestRows(t *testing.T) {
+	ec := newReadTestControl(t)
+
+	var rows []int
+	it := ec.Rows(2)
+	for it.Next() {
+		rows = append(rows, it.Row())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 3, 6}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("Rows(2) == %v, want %v", rows, want)
+	}
+}
+
+func TestIsDateFormat(t *testing.T) {
+	cases := []struct {
+		in   string
+		want bool
+	}{
+		{"yyyy-mm-dd", true},
+		{"dd/mm/yyyy hh:mm", true},
+		{"[h]:mm:ss", true},
+		{"[$-th-TH]d mmm bbbb", true},
+		{"#,##0.00", false},
+		{"0.00E+00", false},
+		{"General", false},
+		{`"Day "0`, false},
+		{"[Red]#,##0", false},
+		{"[$-th-TH]#,##0.00", false},
+	}
+
+	for _, c := range cases {
+		got := isDateFormat(c.in)
+		if got != c.want {
+			t.Errorf("isDateFormat(%q) == %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestReadRangeOtherSheet(t *testing.T) {
+	ec := newReadTestControl(t)
+	if err := ec.SetSheetName("Data", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetActiveSheet(2); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ec.ReadRange("Data!A:B")
+	want := [][]interface{}{{"Doc No", "Amount"}, {"S001", 10.5}, {"S002", 7.0}, {nil, nil}, {nil, nil}, {nil, nil}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadRange(Data!A:B) == %v, %v, want %v", got, err, want)
+	}
+	if _, err := ec.ReadRange("Missing!A1"); err == nil {
+		t.Errorf("ReadRange(Missing!A1) == nil error, want error")
+	}
+}
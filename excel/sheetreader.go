@@ -0,0 +1,489 @@
+package excel
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	gosql "github.com/churikawit/gosrc/sql"
+)
+
+// SheetReaderOptions : where the data is and how sheet columns map to database columns
+type SheetReaderOptions struct {
+	// Range limits the data, e.g. "A1:F500" or "'Upload'!A3:F500", empty means the used area of the active sheet
+	Range string
+	// HeaderRow holds the column names, 0 means the first row of Range
+	HeaderRow int
+	// Columns maps header text (case-insensitive) to the column name returned by GetNames,
+	// when set only these columns are read and each header must exist
+	Columns map[string]string
+	// Types forces the type of a column {VARCHAR, FLOAT, BIGINT, BOOL, DATE}, by name as returned by GetNames
+	// (case-insensitive), columns without a type are inferred from their values
+	Types map[string]string
+	// Required columns must not be empty, by name as returned by GetNames
+	Required []string
+	// Validate is called for every row after conversion, values are in GetNames order
+	Validate func(row int, values []interface{}) error
+	// SkipInvalid skips rows with errors and collects them in RowErrors, otherwise Read stops at the first one
+	SkipInvalid bool
+}
+
+// RowError : a sheet row that could not be converted or did not validate
+type RowError struct {
+	Row    int    // sheet row number [1,n]
+	Cell   string // A1 reference, empty if the error is about the whole row
+	Column string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Cell == "" {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d (%s) %s: %v", e.Row, e.Cell, e.Column, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// SheetReader : rows of a sheet as a forward-only source with DataReader-style methods,
+// satisfies sql.RowSource so it can be passed to BatchInsertFrom
+//
+//	reader, err := ec.NewSheetReader(excel.SheetReaderOptions{
+//		Columns:  map[string]string{"Doc No": "doc_no", "Amount": "amount"},
+//		Required: []string{"doc_no"},
+//	})
+//	count, err := conn.BatchInsertFrom("sales", reader.GetNames(), reader, sql.BatchInsertOptions{})
+type SheetReader struct {
+	opts       SheetReaderOptions
+	names      []string
+	typeNames  []string
+	typeNames2 []string
+	cols       []int // sheet column of each name
+	firstCol   int
+	firstRow   int
+	rows       [][]interface{}
+	pos        int
+	row        int
+	values     []interface{}
+	required   []bool
+	errs       []*RowError
+	err        error
+}
+
+// NewSheetReader : read the header and data of a sheet, types of columns without Types are inferred from the data
+func (ec *ExcelControl) NewSheetReader(opts SheetReaderOptions) (*SheetReader, error) {
+	sheet, err := ec.worksheet("NewSheetReader")
+	if err != nil {
+		return nil, err
+	}
+	rng := usedRange(sheet)
+	if opts.Range != "" {
+		if rng, err = ParseCellRange(opts.Range); err != nil {
+			return nil, ec.newError("NewSheetReader", opts.Range, err)
+		}
+	}
+	if rng.Sheet == "" {
+		rng.Sheet = sheet.Name()
+	}
+	header_row := opts.HeaderRow
+	if header_row <= 0 {
+		header_row = rng.FirstRow
+	}
+
+	header, err := ec.ReadCellRange(CellRange{Sheet: rng.Sheet, FirstRow: header_row, FirstCol: rng.FirstCol, LastRow: header_row, LastCol: rng.LastCol})
+	if err != nil {
+		return nil, err
+	}
+	data := [][]interface{}{}
+	if rng.LastRow > header_row {
+		data, err = ec.ReadCellRange(CellRange{Sheet: rng.Sheet, FirstRow: header_row + 1, FirstCol: rng.FirstCol, LastRow: rng.LastRow, LastCol: rng.LastCol})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := &SheetReader{opts: opts, firstCol: rng.FirstCol, firstRow: header_row + 1, rows: data}
+	if len(header) > 0 {
+		if err := r.mapColumns(header[0]); err != nil {
+			return nil, ec.newError("NewSheetReader", cellName(header_row, rng.FirstCol), err)
+		}
+	}
+	r.required = make([]bool, len(r.names))
+	for _, name := range opts.Required {
+		i := r.indexOf(name)
+		if i < 0 {
+			return nil, ec.newError("NewSheetReader", "", fmt.Errorf("required column %q is not in the sheet", name))
+		}
+		r.required[i] = true
+	}
+	if err := r.inferTypes(); err != nil {
+		return nil, ec.newError("NewSheetReader", "", err)
+	}
+	return r, nil
+}
+
+// mapColumns : names and sheet columns from the header row
+func (r *SheetReader) mapColumns(header []interface{}) error {
+	mapping := map[string]string{}
+	for k, v := range r.opts.Columns {
+		mapping[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	seen := map[string]bool{}
+	for i, h := range header {
+		if h == nil {
+			continue
+		}
+		text := strings.TrimSpace(fmt.Sprint(h))
+		key := strings.ToLower(text)
+		if text == "" {
+			continue
+		}
+		name := text
+		if r.opts.Columns != nil {
+			var ok bool
+			if name, ok = mapping[key]; !ok {
+				continue
+			}
+		}
+		if seen[key] {
+			return fmt.Errorf("header %q appears more than once", text)
+		}
+		seen[key] = true
+		r.names = append(r.names, name)
+		r.cols = append(r.cols, i)
+	}
+	for k := range r.opts.Columns {
+		if !seen[strings.ToLower(strings.TrimSpace(k))] {
+			return fmt.Errorf("header %q is not in the sheet", k)
+		}
+	}
+	return nil
+}
+
+func (r *SheetReader) indexOf(name string) int {
+	for i, n := range r.names {
+		if strings.EqualFold(n, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sheetTypeNames : types accepted by SheetReaderOptions.Types
+var sheetTypeNames = map[string]bool{"VARCHAR": true, "FLOAT": true, "BIGINT": true, "BOOL": true, "DATE": true}
+
+// inferTypes : BOOL, DATE, BIGINT or FLOAT when every value of a column has that type, otherwise VARCHAR,
+// VARCHAR columns get VARCHAR(n) as secondary type name with n the longest text
+func (r *SheetReader) inferTypes() error {
+	forced := map[int]string{}
+	for name, t := range r.opts.Types {
+		i := r.indexOf(name)
+		if i < 0 {
+			return fmt.Errorf("type of column %q that is not in the sheet", name)
+		}
+		typeName := strings.ToUpper(strings.TrimSpace(t))
+		if !sheetTypeNames[typeName] {
+			return fmt.Errorf("column %q: unknown type %q, want VARCHAR, FLOAT, BIGINT, BOOL or DATE", name, t)
+		}
+		forced[i] = typeName
+	}
+
+	r.typeNames = make([]string, len(r.names))
+	r.typeNames2 = make([]string, len(r.names))
+	for i := range r.names {
+		typeName, ok := forced[i]
+		if !ok {
+			for _, row := range r.rows {
+				v := row[r.cols[i]]
+				if isEmptyValue(v) {
+					continue
+				}
+				t := valueTypeName(v)
+				if typeName == "" || (typeName == "BIGINT" && t == "FLOAT") {
+					typeName = t
+				} else if typeName != t && !(typeName == "FLOAT" && t == "BIGINT") {
+					typeName = "VARCHAR"
+					break
+				}
+			}
+			if typeName == "" {
+				typeName = "VARCHAR"
+			}
+		}
+		r.typeNames[i] = typeName
+		r.typeNames2[i] = typeName
+		if typeName == "VARCHAR" {
+			length := 1
+			for _, row := range r.rows {
+				if v := row[r.cols[i]]; !isEmptyValue(v) {
+					if n := utf8.RuneCountInString(toString(v)); n > length {
+						length = n
+					}
+				}
+			}
+			r.typeNames2[i] = fmt.Sprintf("VARCHAR(%d)", length)
+		}
+	}
+	return nil
+}
+
+func valueTypeName(v interface{}) string {
+	switch s2 := v.(type) {
+	case bool:
+		return "BOOL"
+	case time.Time:
+		return "DATE"
+	case float64:
+		if s2 == math.Trunc(s2) && math.Abs(s2) < 1<<53 {
+			return "BIGINT"
+		}
+		return "FLOAT"
+	}
+	return "VARCHAR"
+}
+
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// Read : move to the next non-empty row, false at the end or at the first invalid row unless SkipInvalid
+func (r *SheetReader) Read() bool {
+	for r.err == nil && r.pos < len(r.rows) {
+		row := r.rows[r.pos]
+		r.row = r.firstRow + r.pos
+		r.pos++
+
+		empty := true
+		for _, c := range r.cols {
+			if !isEmptyValue(row[c]) {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			continue
+		}
+
+		values, err := r.convertRow(row)
+		if err == nil && r.opts.Validate != nil {
+			if err = r.opts.Validate(r.row, values); err != nil {
+				err = &RowError{Row: r.row, Err: err}
+			}
+		}
+		if err != nil {
+			var re *RowError
+			if !errors.As(err, &re) {
+				re = &RowError{Row: r.row, Err: err}
+			}
+			r.errs = append(r.errs, re)
+			if r.opts.SkipInvalid {
+				continue
+			}
+			r.err = re
+			return false
+		}
+		r.values = values
+		return true
+	}
+	return false
+}
+
+func (r *SheetReader) convertRow(row []interface{}) ([]interface{}, error) {
+	values := make([]interface{}, len(r.names))
+	for i, c := range r.cols {
+		v, err := convertValue(row[c], r.typeNames[i])
+		if err == nil && v == nil && r.required[i] {
+			err = errors.New("value is required")
+		}
+		if err != nil {
+			return nil, &RowError{Row: r.row, Cell: cellName(r.row, r.firstCol+c), Column: r.names[i], Err: err}
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// convertValue : sheet value to the Go type of typeName, see SheetReader.GetDataTypeName
+func convertValue(v interface{}, typeName string) (interface{}, error) {
+	if isEmptyValue(v) {
+		return nil, nil
+	}
+	switch typeName {
+	case "FLOAT":
+		return toFloat(v)
+	case "BIGINT":
+		f, err := toFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		if f != math.Trunc(f) || f >= 1<<63 || f < -1<<63 {
+			return nil, fmt.Errorf("%v is not a whole number", v)
+		}
+		return int64(f), nil
+	case "BOOL":
+		return toBool(v)
+	case "DATE":
+		return toTime(v)
+	}
+	return toString(v), nil
+}
+
+func toString(v interface{}) string {
+	switch s2 := v.(type) {
+	case string:
+		return s2
+	case float64:
+		return strconv.FormatFloat(s2, 'f', -1, 64)
+	case time.Time:
+		if s2.Hour() == 0 && s2.Minute() == 0 && s2.Second() == 0 {
+			return s2.Format("2006-01-02")
+		}
+		return s2.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(v)
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch s2 := v.(type) {
+	case float64:
+		return s2, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s2), ",", ""), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", s2)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+// toBool : true/false, yes/no, y/n, 1/0 and the Thai ใช่/ไม่ใช่, มี/ไม่มี, จริง/เท็จ
+func toBool(v interface{}) (bool, error) {
+	switch s2 := v.(type) {
+	case bool:
+		return s2, nil
+	case float64:
+		if s2 == 0 || s2 == 1 {
+			return s2 == 1, nil
+		}
+	case string:
+		switch strings.ToLower(strings.TrimSpace(s2)) {
+		case "true", "yes", "y", "1", "ใช่", "มี", "จริง", "ถูก", "✓":
+			return true, nil
+		case "false", "no", "n", "0", "ไม่ใช่", "ไม่มี", "เท็จ", "ไม่", "ผิด":
+			return false, nil
+		}
+		return false, fmt.Errorf("%q is not yes or no", s2)
+	}
+	return false, fmt.Errorf("%v is not yes or no", v)
+}
+
+// dateLayouts : text dates accepted in uploads, day first as written in Thailand
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.RFC3339,
+	"02/01/2006",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"2/1/2006",
+}
+
+// toTime : date cell, date serial number or text date
+func toTime(v interface{}) (time.Time, error) {
+	switch s2 := v.(type) {
+	case time.Time:
+		return s2, nil
+	case float64:
+		return DateFromSerial(s2), nil
+	case string:
+		s := strings.TrimSpace(s2)
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("%q is not a date", s2)
+	}
+	return time.Time{}, fmt.Errorf("%v is not a date", v)
+}
+
+// DateFromSerial : Excel date serial number (days since 1899-12-30) to time in UTC
+func DateFromSerial(serial float64) time.Time {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return epoch.Add(time.Duration(math.Round(serial*86400*1000)) * time.Millisecond)
+}
+
+// GetNames : column names, mapped by Columns if set
+func (r *SheetReader) GetNames() []string {
+	output := make([]string, len(r.names))
+	copy(output, r.names)
+	return output
+}
+
+// GetDataTypeName : return {VARCHAR, FLOAT, BIGINT, BOOL, DATE}
+func (r *SheetReader) GetDataTypeName(i int) string {
+	if i < 0 || i >= len(r.typeNames) {
+		return ""
+	}
+	return r.typeNames[i]
+}
+
+// GetDataTypeName2 : GetDataTypeName with the length of VARCHAR, e.g. VARCHAR(20)
+func (r *SheetReader) GetDataTypeName2(i int) string {
+	if i < 0 || i >= len(r.typeNames2) {
+		return ""
+	}
+	return r.typeNames2[i]
+}
+
+// FieldCount : return number of column
+func (r *SheetReader) FieldCount() int {
+	return len(r.names)
+}
+
+// GetValues : values of the current row, string, float64, int64, bool, time.Time or nil
+func (r *SheetReader) GetValues() []interface{} {
+	return r.values
+}
+
+// Row : sheet row number [1,n] of the current row
+func (r *SheetReader) Row() int {
+	return r.row
+}
+
+// Err : the *RowError that stopped Read, nil when SkipInvalid is set
+func (r *SheetReader) Err() error {
+	return r.err
+}
+
+// RowErrors : every row error met so far
+func (r *SheetReader) RowErrors() []*RowError {
+	return r.errs
+}
+
+// ReadDataTable : all valid rows of a sheet in a sql.DataTable, use DataTable.Reader for a *sql.DataReader
+func (ec *ExcelControl) ReadDataTable(opts SheetReaderOptions) (*gosql.DataTable, []*RowError, error) {
+	r, err := ec.NewSheetReader(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	dt := &gosql.DataTable{Columns: r.GetNames(), TypeNames: r.typeNames, TypeNames2: r.typeNames2}
+	for r.Read() {
+		dt.Rows = append(dt.Rows, r.GetValues())
+	}
+	return dt, r.RowErrors(), r.Err()
+}
+
+var _ gosql.RowSource = (*SheetReader)(nil)
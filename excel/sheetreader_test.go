@@ -0,0 +1,252 @@
+package excel
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	gosql "github.com/churikawit/gosrc/sql"
+	"github.com/churikawit/gosrc/sql/sqltest"
+)
+
+func newUploadControl(t *testing.T) *ExcelControl {
+	ec := New()
+	if err := ec.OpenOrCreate("upload.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]interface{}{
+		{"Sales upload"},
+		{},
+		{"Doc No", "Amount", "Qty", "Paid", "Doc Date", "Remark"},
+		{"S001", 10.5, 3.0, "ใช่", "01/06/2021", "first"},
+		{"S002", 20.0, 1.0, "no", 44347.0},
+		{},
+		{"", "abc", 2.0, true, "2021-06-03"},
+		{"S004", 5.0, 2.5, "maybe", "2021-06-04"},
+	}
+	for i, row := range rows {
+		if err := ec.WriteRow(row, i+1, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ec
+}
+
+func uploadOptions() SheetReaderOptions {
+	return SheetReaderOptions{
+		HeaderRow: 3,
+		Columns:   map[string]string{"doc no": "doc_no", "Amount": "amount", "Qty": "qty", "Paid": "paid", "Doc Date": "doc_date"},
+		Types:     map[string]string{"amount": "FLOAT", "paid": "BOOL", "doc_date": "DATE"},
+		Required:  []string{"doc_no"},
+	}
+}
+
+func TestSheetReader(t *testing.T) {
+	ec := newUploadControl(t)
+	opts := uploadOptions()
+	opts.SkipInvalid = true
+	r, err := ec.NewSheetReader(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := r.GetNames(), []string{"doc_no", "amount", "qty", "paid", "doc_date"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetNames() == %v, want %v", got, want)
+	}
+	var types []string
+	for i := 0; i < r.FieldCount(); i++ {
+		types = append(types, r.GetDataTypeName(i))
+	}
+	if want := []string{"VARCHAR", "FLOAT", "FLOAT", "BOOL", "DATE"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("GetDataTypeName() == %v, want %v", types, want)
+	}
+
+	var got [][]interface{}
+	var rows []int
+	for r.Read() {
+		got = append(got, r.GetValues())
+		rows = append(rows, r.Row())
+	}
+	want := [][]interface{}{
+		{"S001", 10.5, 3.0, true, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"S002", 20.0, 1.0, false, time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	if r.Err() != nil || !reflect.DeepEqual(got, want) || !reflect.DeepEqual(rows, []int{4, 5}) {
+		t.Errorf("Read() == %v at rows %v, %v, want %v", got, rows, r.Err(), want)
+	}
+
+	var msgs []string
+	for _, e := range r.RowErrors() {
+		msgs = append(msgs, e.Error())
+	}
+	wantMsgs := []string{
+		"row 7 (A7) doc_no: value is required",
+		`row 8 (D8) paid: "maybe" is not yes or no`,
+	}
+	if !reflect.DeepEqual(msgs, wantMsgs) {
+		t.Errorf("RowErrors() == %q, want %q", msgs, wantMsgs)
+	}
+}
+
+func TestSheetReaderStopsAtFirstError(t *testing.T) {
+	ec := newUploadControl(t)
+	opts := uploadOptions()
+	opts.Validate = func(row int, values []interface{}) error {
+		if values[1].(float64) > 15 {
+			return errors.New("amount over limit")
+		}
+		return nil
+	}
+	r, err := ec.NewSheetReader(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for r.Read() {
+		count++
+	}
+	var re *RowError
+	if count != 1 || !errors.As(r.Err(), &re) || re.Row != 5 || re.Error() != "row 5: amount over limit" {
+		t.Errorf("Read() %d rows, Err() == %v", count, r.Err())
+	}
+}
+
+func TestSheetReaderMissingHeader(t *testing.T) {
+	ec := newUploadControl(t)
+	opts := uploadOptions()
+	opts.Columns["Branch"] = "branch"
+	if _, err := ec.NewSheetReader(opts); err == nil {
+		t.Errorf("NewSheetReader with missing header == nil error, want error")
+	}
+}
+
+func TestSheetReaderBatchInsert(t *testing.T) {
+	ec := newUploadControl(t)
+	opts := uploadOptions()
+	opts.Range = "A3:E5"
+	r, err := ec.NewSheetReader(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := sqltest.New()
+	fake.ExpectExec(`INSERT INTO "sales" ("doc_no", "amount", "qty", "paid", "doc_date") VALUES ($1, $2, $3, $4, $5), ($6, $7, $8, $9, $10)`).
+		WithArgs("S001", 10.5, int64(3), true, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+			"S002", 20.0, int64(1), false, time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC)).
+		WillReturnResult(0, 2)
+	conn := gosql.NewPostgresConnector2(fake.DSN())
+	conn.DriverName = sqltest.DriverName
+	conn.SetLogger(gosql.NopLogger())
+	if err := conn.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseConnection()
+
+	count, err := conn.BatchInsertFrom("sales", r.GetNames(), r, gosql.BatchInsertOptions{})
+	if err != nil || count != 2 {
+		t.Errorf("BatchInsertFrom == %d, %v, want 2", count, err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadDataTable(t *testing.T) {
+	ec := newUploadControl(t)
+	opts := uploadOptions()
+	opts.SkipInvalid = true
+	dt, rowErrs, err := ec.ReadDataTable(opts)
+	if err != nil || dt.RowCount() != 2 || len(rowErrs) != 2 {
+		t.Fatalf("ReadDataTable == %d rows, %d row errors, %v", dt.RowCount(), len(rowErrs), err)
+	}
+
+	reader := dt.Reader()
+	defer reader.Close()
+	reader.Read()
+	if got := reader.GetValues(); got[0] != "S001" || got[3] != true {
+		t.Errorf("DataTable.Reader() first row == %v", got)
+	}
+}
+
+func TestSheetReaderTypes(t *testing.T) {
+	ec := newUploadControl(t)
+	opts := uploadOptions()
+	opts.Types = map[string]string{"AMOUNT": "float", "Paid": "BOOL", "doc_date": "date"}
+	opts.Range = "A3:E5"
+	r, err := ec.NewSheetReader(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types, types2 []string
+	for i := 0; i < r.FieldCount(); i++ {
+		types = append(types, r.GetDataTypeName(i))
+		types2 = append(types2, r.GetDataTypeName2(i))
+	}
+	if want := []string{"VARCHAR", "FLOAT", "BIGINT", "BOOL", "DATE"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("GetDataTypeName() == %v, want %v", types, want)
+	}
+	if want := []string{"VARCHAR(4)", "FLOAT", "BIGINT", "BOOL", "DATE"}; !reflect.DeepEqual(types2, want) {
+		t.Errorf("GetDataTypeName2() == %v, want %v", types2, want)
+	}
+
+	dt, _, err := ec.ReadDataTable(opts)
+	if err != nil || !reflect.DeepEqual(dt.TypeNames2, types2) {
+		t.Errorf("ReadDataTable TypeNames2 == %v, %v, want %v", dt.TypeNames2, err, types2)
+	}
+
+	for _, types := range []map[string]string{{"amount": "MONEY"}, {"branch": "VARCHAR"}} {
+		opts.Types = types
+		if _, err := ec.NewSheetReader(opts); err == nil {
+			t.Errorf("NewSheetReader with Types %v == nil error, want error", types)
+		}
+	}
+}
+
+func TestConvertValueBigint(t *testing.T) {
+	cases := []struct {
+		in      float64
+		want    interface{}
+		wantErr bool
+	}{
+		{42, int64(42), false},
+		{-1 << 63, int64(math.MinInt64), false},
+		{1 << 62, int64(1 << 62), false},
+		{1 << 63, nil, true},
+		{-1 << 64, nil, true},
+		{1.5, nil, true},
+	}
+	for _, c := range cases {
+		got, err := convertValue(c.in, "BIGINT")
+		if got != c.want || (err != nil) != c.wantErr {
+			t.Errorf("convertValue(%v, BIGINT) == %v, %v, want %v", c.in, got, err, c.want)
+		}
+	}
+}
+
+func TestToBool(t *testing.T) {
+	cases := []struct {
+		in      interface{}
+		want    bool
+		wantErr bool
+	}{
+		{"Yes", true, false},
+		{" n ", false, false},
+		{"ใช่", true, false},
+		{"ไม่ใช่", false, false},
+		{"มี", true, false},
+		{1.0, true, false},
+		{0.0, false, false},
+		{2.0, false, true},
+		{"maybe", false, true},
+	}
+
+	for _, c := range cases {
+		got, err := toBool(c.in)
+		if got != c.want || (err != nil) != c.wantErr {
+			t.Errorf("toBool(%#v) == %v, %v, want %v", c.in, got, err, c.want)
+		}
+	}
+}
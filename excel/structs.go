@@ -0,0 +1,503 @@
+package excel
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// structField : one column of a struct written by WriteStructs
+//
+// tag syntax, options after the header text separated by commas, format must come last
+// because number formats contain commas:
+//
+//	OrderNo string    `xlsx:"Order No"`
+//	Amount  float64   `xlsx:"Amount,order=3,format=#,##0.00"`
+//	Created time.Time `xlsx:",format=yyyy-mm-dd"`   // header is the field name
+//	Secret  string    `xlsx:"-"`                    // not written
+//	Address Address   `xlsx:"Ship"`                 // nested struct, headers "Ship Street", "Ship City"
+//	Email   string    `xlsx:"Email,required"`       // ReadStructs reports rows without it
+type structField struct {
+	index    []int
+	header   string
+	format   string
+	order    int
+	hasOrder bool
+	required bool
+	typ      reflect.Type
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	stringerT  = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// structFields : columns of struct type t in sheet order
+func structFields(t reflect.Type) ([]structField, error) {
+	fields, err := appendFields(nil, t, nil, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if a.hasOrder != b.hasOrder {
+			return a.hasOrder // fields with order= come first
+		}
+		return a.hasOrder && a.order < b.order
+	})
+	return fields, nil
+}
+
+func appendFields(fields []structField, t reflect.Type, index []int, prefix string, depth int) ([]structField, error) {
+	if depth > 10 {
+		return nil, fmt.Errorf("struct %s is nested too deep", t)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("xlsx")
+		if tag == "-" {
+			continue
+		}
+		if f.PkgPath != "" && !f.Anonymous {
+			continue // unexported
+		}
+
+		sf := structField{index: append(append([]int{}, index...), i), header: f.Name}
+		name, opts := tag, ""
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			name, opts = tag[:j], tag[j+1:]
+		}
+		if name != "" {
+			sf.header = name
+		}
+		for opts != "" {
+			opt := opts
+			if strings.HasPrefix(opt, "format=") {
+				sf.format = strings.TrimPrefix(opt, "format=")
+				break
+			}
+			if j := strings.IndexByte(opts, ','); j >= 0 {
+				opt, opts = opts[:j], opts[j+1:]
+			} else {
+				opts = ""
+			}
+			switch {
+			case strings.HasPrefix(opt, "order="):
+				n, err := strconv.Atoi(strings.TrimPrefix(opt, "order="))
+				if err != nil {
+					return nil, fmt.Errorf("field %s: invalid xlsx tag option %q", f.Name, opt)
+				}
+				sf.order, sf.hasOrder = n, true
+			case opt == "required":
+				sf.required = true
+			case opt != "":
+				return nil, fmt.Errorf("field %s: unknown xlsx tag option %q", f.Name, opt)
+			}
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if isStructValue(ft) {
+			// embedded structs add their fields as they are, named ones under the field header
+			sub := prefix
+			if !f.Anonymous || (hasTag && name != "") {
+				sub = prefix + sf.header + " "
+			}
+			var err error
+			if fields, err = appendFields(fields, ft, sf.index, sub, depth+1); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue // unexported embedded non-struct
+		}
+		sf.header = prefix + sf.header
+		sf.typ = f.Type
+		fields = append(fields, sf)
+	}
+	return fields, nil
+}
+
+// isStructValue : struct to flatten, not a struct written as one cell like time.Time or sql.NullString
+func isStructValue(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	return !(t.Implements(valuerType) || reflect.PtrTo(t).Implements(valuerType) ||
+		t.Implements(stringerT) || reflect.PtrTo(t).Implements(stringerT))
+}
+
+// fieldValue : value of a flattened field, nil if a pointer on the way is nil
+func fieldValue(v reflect.Value, index []int) interface{} {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v.Interface()
+}
+
+// structSliceType : element struct type of a slice or array of structs or struct pointers
+func structSliceType(t reflect.Type) (reflect.Type, error) {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return nil, fmt.Errorf("want a slice of structs, got %s", t)
+	}
+	et := t.Elem()
+	if et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("want a slice of structs, got %s", t)
+	}
+	return et, nil
+}
+
+// -----------------------------------------------------------------------------------------------------------
+
+// WriteStructs : write a slice of structs (or struct pointers) as a bold header row at (first_row, first_col)
+// and one row per element, columns come from the xlsx struct tags, see structField
+// return the range written including the header
+func (ec *ExcelControl) WriteStructs(slice interface{}, first_row, first_col int) (CellRange, error) {
+	rv := reflect.ValueOf(slice)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return CellRange{}, ec.newError("WriteStructs", "", fmt.Errorf("want a slice of structs, got nil"))
+	}
+	et, err := structSliceType(rv.Type())
+	if err != nil {
+		return CellRange{}, ec.newError("WriteStructs", "", err)
+	}
+	fields, err := structFields(et)
+	if err != nil {
+		return CellRange{}, ec.newError("WriteStructs", "", err)
+	}
+	if len(fields) == 0 {
+		return CellRange{}, ec.newError("WriteStructs", "", fmt.Errorf("struct %s has no exported fields", et))
+	}
+	last_col := first_col + len(fields) - 1
+	if _, _, err := ec.cellRef("WriteStructs", first_row+rv.Len(), last_col); err != nil {
+		return CellRange{}, err
+	}
+
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.header
+	}
+	if err := ec.WriteHeader(header, first_row, first_col); err != nil {
+		return CellRange{}, err
+	}
+	hs := ec.headerStyle()
+	for i := range header {
+		ec.m_Worksheet.Cell(cellName(first_row, first_col+i)).SetStyle(hs)
+	}
+
+	datarow := make([]interface{}, len(fields))
+	for n := 0; n < rv.Len(); n++ {
+		row := first_row + 1 + n
+		item := rv.Index(n)
+		for i, f := range fields {
+			datarow[i] = fieldValue(item, f.index)
+		}
+		if err := ec.WriteRow(datarow, row, first_col); err != nil {
+			return CellRange{}, err
+		}
+		for i, f := range fields {
+			if f.format != "" && !isNilValue(datarow[i]) {
+				ec.applyStyle(ec.m_Worksheet.Cell(cellName(row, first_col+i)), Style{NumberFormat: f.format})
+			}
+		}
+	}
+
+	output := NewCellRange(first_row, first_col, first_row+rv.Len(), last_col)
+	output.Sheet = ec.m_Worksheet.Name()
+	return output, nil
+}
+
+// isNilValue : nil, nil pointer or zero time, written as an empty cell
+func isNilValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if t, ok := v.(time.Time); ok {
+		return t.IsZero()
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// -----------------------------------------------------------------------------------------------------------
+
+// ReadStructs : read the rows below header_row = [1,n] of the active sheet into out, a pointer to a slice
+// of structs or struct pointers, columns are matched to fields by the xlsx header text (case-insensitive)
+// cells are converted to the field type: numbers, dates (date cells, serial numbers or text),
+// booleans (true/false, yes/no, ใช่/ไม่ใช่ ...), pointers and sql.Null* types
+// rows that cannot be converted or miss a required field are skipped and returned as row errors,
+// the error is for problems with out or the header, such as a missing column of a required field
+func (ec *ExcelControl) ReadStructs(out interface{}, header_row int) ([]*RowError, error) {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return nil, ec.newError("ReadStructs", "", fmt.Errorf("want a pointer to a slice of structs, got %T", out))
+	}
+	slice := rv.Elem()
+	et, err := structSliceType(slice.Type())
+	if err != nil {
+		return nil, ec.newError("ReadStructs", "", err)
+	}
+	fields, err := structFields(et)
+	if err != nil {
+		return nil, ec.newError("ReadStructs", "", err)
+	}
+	for _, f := range fields {
+		if !canConvertTo(f.typ) {
+			return nil, ec.newError("ReadStructs", "", fmt.Errorf("field %q: unsupported type %s", f.header, f.typ))
+		}
+		if t := unexportedPtr(et, f.index); t != nil {
+			return nil, ec.newError("ReadStructs", "", fmt.Errorf("field %q: cannot set embedded pointer to unexported struct %s", f.header, t))
+		}
+	}
+
+	sheet, err := ec.worksheet("ReadStructs")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := FormatCellRef(header_row, 1); err != nil {
+		return nil, ec.newError("ReadStructs", cellName(header_row, 1), err)
+	}
+	used := usedRange(sheet)
+	header, err := ec.ReadCellRange(NewCellRange(header_row, 1, header_row, maxInt(used.LastCol, 1)))
+	if err != nil {
+		return nil, err
+	}
+
+	// sheet column of each field, -1 if the sheet does not have it
+	cols := make([]int, len(fields))
+	for i, f := range fields {
+		cols[i] = -1
+		for c, h := range header[0] {
+			if h != nil && strings.EqualFold(strings.TrimSpace(fmt.Sprint(h)), f.header) {
+				cols[i] = c
+				break
+			}
+		}
+		if cols[i] < 0 && f.required {
+			return nil, ec.newError("ReadStructs", cellName(header_row, 1), fmt.Errorf("no column %q for required field", f.header))
+		}
+	}
+	if used.LastRow <= header_row {
+		return nil, nil
+	}
+	data, err := ec.ReadCellRange(NewCellRange(header_row+1, 1, used.LastRow, used.LastCol))
+	if err != nil {
+		return nil, err
+	}
+
+	var rowErrs []*RowError
+	isPtr := slice.Type().Elem().Kind() == reflect.Ptr
+	for n, values := range data {
+		row := header_row + 1 + n
+		empty := true
+		for _, v := range values {
+			if !isEmptyValue(v) {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			continue
+		}
+
+		item := reflect.New(et)
+		failed := false
+		for i, f := range fields {
+			var v interface{}
+			if cols[i] >= 0 {
+				v = values[cols[i]]
+			}
+			err := error(nil)
+			if isEmptyValue(v) {
+				if f.required {
+					err = fmt.Errorf("value is required")
+				}
+			} else {
+				err = setField(fieldByIndexAlloc(item.Elem(), f.index), v)
+			}
+			if err != nil {
+				cell := ""
+				if cols[i] >= 0 {
+					cell = cellName(row, cols[i]+1)
+				}
+				rowErrs = append(rowErrs, &RowError{Row: row, Cell: cell, Column: f.header, Err: err})
+				failed = true
+			}
+		}
+		if failed {
+			continue
+		}
+		if isPtr {
+			slice.Set(reflect.Append(slice, item))
+		} else {
+			slice.Set(reflect.Append(slice, item.Elem()))
+		}
+	}
+	return rowErrs, nil
+}
+
+// fieldByIndexAlloc : like reflect.Value.FieldByIndex but allocate nil struct pointers on the way
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// unexportedPtr : unexported embedded struct pointer on the way to the field at index, nil if none,
+// reflect cannot allocate it, so ReadStructs refuses it like encoding/json does
+func unexportedPtr(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		f := t.Field(i)
+		if f.Anonymous && f.PkgPath != "" && f.Type.Kind() == reflect.Ptr {
+			return f.Type.Elem()
+		}
+		t = f.Type
+	}
+	return nil
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// canConvertTo : setField can set a field of type t
+func canConvertTo(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(scannerType) || t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return canConvertTo(t.Elem())
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	}
+	return false
+}
+
+// nullValueType : value type of sql.NullString, sql.NullInt64 ... and scanners shaped like them
+// (the value and a Valid bool), nil for other types
+func nullValueType(t reflect.Type) reflect.Type {
+	if t.Kind() != reflect.Struct || t.NumField() != 2 {
+		return nil
+	}
+	value, valid := t.Field(0), t.Field(1)
+	if valid.Name != "Valid" || valid.Type.Kind() != reflect.Bool || value.PkgPath != "" || !canConvertTo(value.Type) {
+		return nil
+	}
+	return value.Type
+}
+
+// setField : convert a cell value (string, float64, bool or time.Time) into the field
+func setField(fv reflect.Value, v interface{}) error {
+	t := fv.Type()
+	if t == timeType {
+		d, err := toTime(v)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(d))
+		return nil
+	}
+	if reflect.PtrTo(t).Implements(scannerType) {
+		if vt := nullValueType(t); vt != nil {
+			// sql.NullBool, sql.NullTime ... get the value converted like a field of their value type
+			p := reflect.New(vt).Elem()
+			if err := setField(p, v); err != nil {
+				return err
+			}
+			v = p.Interface()
+		} else if f, ok := v.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			v = int64(f) // other scanners want whole numbers as integers
+		}
+		return fv.Addr().Interface().(sql.Scanner).Scan(v)
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(t.Elem())
+		if err := setField(p.Elem(), v); err != nil {
+			return err
+		}
+		fv.Set(p)
+	case reflect.Interface:
+		fv.Set(reflect.ValueOf(v))
+	case reflect.String:
+		fv.SetString(toString(v))
+	case reflect.Bool:
+		b, err := toBool(v)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat(v)
+		if err != nil {
+			return err
+		}
+		if fv.OverflowFloat(f) {
+			return fmt.Errorf("%v is out of range for %s", v, t)
+		}
+		fv.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, err := toFloat(v)
+		if err != nil {
+			return err
+		}
+		if f != math.Trunc(f) {
+			return fmt.Errorf("%v is not a whole number", v)
+		}
+		if f < math.MinInt64 || f >= math.MaxInt64 || fv.OverflowInt(int64(f)) {
+			return fmt.Errorf("%v is out of range for %s", v, t)
+		}
+		fv.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, err := toFloat(v)
+		if err != nil {
+			return err
+		}
+		if f != math.Trunc(f) {
+			return fmt.Errorf("%v is not a whole number", v)
+		}
+		if f < 0 || f >= math.MaxUint64 || fv.OverflowUint(uint64(f)) {
+			return fmt.Errorf("%v is out of range for %s", v, t)
+		}
+		fv.SetUint(uint64(f))
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package excel
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testAudit struct {
+	CreatedBy string    `xlsx:"Created By"`
+	CreatedAt time.Time `xlsx:"Created At,format=yyyy-mm-dd hh:mm"`
+}
+
+type testAddress struct {
+	Street string
+	City   string `xlsx:"City"`
+}
+
+type testOrder struct {
+	testAudit
+	OrderNo  string         `xlsx:"Order No,order=1"`
+	Amount   float64        `xlsx:"Amount,order=2,format=#,##0.00"`
+	Customer string         `xlsx:"Customer"`
+	Ship     *testAddress   `xlsx:"Ship"`
+	Note     sql.NullString `xlsx:"Note"`
+	Secret   string         `xlsx:"-"`
+	internal int
+}
+
+func TestStructFields(t *testing.T) {
+	fields, err := structFields(reflect.TypeOf(testOrder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range fields {
+		got = append(got, f.header+"|"+f.format)
+	}
+	want := []string{
+		"Order No|", "Amount|#,##0.00", "Created By|", "Created At|yyyy-mm-dd hh:mm",
+		"Customer|", "Ship Street|", "Ship City|", "Note|",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("structFields(testOrder) == %q, want %q", got, want)
+	}
+
+	type bad struct {
+		A int `xlsx:"A,order=x"`
+	}
+	if _, err := structFields(reflect.TypeOf(bad{})); err == nil {
+		t.Errorf("structFields with order=x == nil error, want error")
+	}
+}
+
+func TestWriteStructs(t *testing.T) {
+	created := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	orders := []*testOrder{
+		{testAudit{"somchai", created}, "S001", 1500.5, "ACME", &testAddress{"Sukhumvit", "Bangkok"}, sql.NullString{String: "rush", Valid: true}, "x", 1},
+		{OrderNo: "S002", Amount: 20},
+	}
+
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	rng, err := ec.WriteStructs(orders, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rng.Sheet = ""; rng.String() != "A1:H3" {
+		t.Errorf("WriteStructs range == %q, want A1:H3", rng.String())
+	}
+
+	got, err := ec.ReadRange("A1:H3")
+	want := [][]interface{}{
+		{"Order No", "Amount", "Created By", "Created At", "Customer", "Ship Street", "Ship City", "Note"},
+		{"S001", 1500.5, "somchai", created, "ACME", "Sukhumvit", "Bangkok", "rush"},
+		{"S002", 20.0, "", nil, "", nil, nil, nil},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadRange(A1:H3) == %v, %v, want %v", got, err, want)
+	}
+
+	if _, err := ec.WriteStructs([]int{1}, 1, 1); err == nil {
+		t.Errorf("WriteStructs([]int) == nil error, want error")
+	}
+}
+
+type testUpload struct {
+	Code     string  `xlsx:"Code,required"`
+	Name     *string `xlsx:"Name"`
+	Qty      int16   `xlsx:"Qty"`
+	Price    float64 `xlsx:"Price"`
+	Active   bool    `xlsx:"Active"`
+	Since    time.Time
+	Discount sql.NullFloat64 `xlsx:"Discount"`
+	Ship     struct {
+		City string `xlsx:"City"`
+	} `xlsx:"Ship"`
+	Paid    sql.NullBool
+	Shipped sql.NullTime
+	Stock   sql.NullInt64
+}
+
+func TestReadStructs(t *testing.T) {
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]interface{}{
+		{"code", "Name", "Qty", "Price", "Active", "Since", "Discount", "Ship City", "Extra", "Paid", "Shipped", "Stock"},
+		{"P1", "Pen", 10.0, "1,250.50", "ใช่", 44348.0, 0.5, "Bangkok", "x", "yes", 44349.0, "1,234"},
+		{"P2", nil, "3", 2.0, "No", "2021-06-02", nil, nil, nil, "ไม่ใช่", "2021-06-03"},
+		{},
+		{nil, "No code", 1.0},
+		{"P4", "Bad", 2.5, "abc", "maybe", "soon", nil, nil, nil, "maybe", "soon", 1.5},
+		{"P5", "Big", 40000.0},
+	}
+	for i, row := range rows {
+		if err := ec.WriteRow(row, i+1, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []testUpload
+	rowErrs, err := ec.ReadStructs(&got, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pen := "Pen"
+	want := []testUpload{
+		{Code: "P1", Name: &pen, Qty: 10, Price: 1250.5, Active: true, Since: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+			Discount: sql.NullFloat64{Float64: 0.5, Valid: true}, Paid: sql.NullBool{Bool: true, Valid: true},
+			Shipped: sql.NullTime{Time: time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC), Valid: true},
+			Stock:   sql.NullInt64{Int64: 1234, Valid: true}},
+		{Code: "P2", Qty: 3, Price: 2, Since: time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC), Paid: sql.NullBool{Valid: true},
+			Shipped: sql.NullTime{Time: time.Date(2021, 6, 3, 0, 0, 0, 0, time.UTC), Valid: true}},
+	}
+	want[0].Ship.City = "Bangkok"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadStructs() == %+v, want %+v", got, want)
+	}
+
+	var msgs []string
+	for _, e := range rowErrs {
+		msgs = append(msgs, e.Error())
+	}
+	wantMsgs := []string{
+		"row 5 (A5) Code: value is required",
+		"row 6 (C6) Qty: 2.5 is not a whole number",
+		`row 6 (D6) Price: "abc" is not a number`,
+		`row 6 (E6) Active: "maybe" is not yes or no`,
+		`row 6 (F6) Since: "soon" is not a date`,
+		`row 6 (J6) Paid: "maybe" is not yes or no`,
+		`row 6 (K6) Shipped: "soon" is not a date`,
+		"row 6 (L6) Stock: 1.5 is not a whole number",
+		"row 7 (C7) Qty: 40000 is out of range for int16",
+	}
+	if !reflect.DeepEqual(msgs, wantMsgs) {
+		t.Errorf("ReadStructs() row errors == %q, want %q", msgs, wantMsgs)
+	}
+}
+
+func TestReadStructsErrors(t *testing.T) {
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.WriteRow([]interface{}{"Name", "ID"}, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.WriteRow([]interface{}{"Pen", 7.0}, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var uploads []*testUpload
+	if _, err := ec.ReadStructs(&uploads, 1); err == nil {
+		t.Errorf("ReadStructs without required column == nil error, want error")
+	}
+	if _, err := ec.ReadStructs(uploads, 1); err == nil {
+		t.Errorf("ReadStructs(slice) == nil error, want error")
+	}
+	var bad []struct{ C chan int }
+	if _, err := ec.ReadStructs(&bad, 1); err == nil {
+		t.Errorf("ReadStructs(chan field) == nil error, want error")
+	}
+	var embedded []testEmbedded
+	if _, err := ec.ReadStructs(&embedded, 1); err == nil {
+		t.Errorf("ReadStructs(unexported embedded pointer) == nil error, want error")
+	}
+	var exported []struct {
+		*TestBase
+		Name string
+	}
+	if _, err := ec.ReadStructs(&exported, 1); err != nil || len(exported) != 1 || exported[0].ID != 7 || exported[0].Name != "Pen" {
+		t.Errorf("ReadStructs(exported embedded pointer) == %+v, %v, want ID 7 and Pen", exported, err)
+	}
+}
+
+type testBase struct{ ID int }
+
+// TestBase : exported embedded struct, ReadStructs can allocate it
+type TestBase struct{ ID int }
+
+type testEmbedded struct {
+	*testBase
+	Name string
+}
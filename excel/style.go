@@ -0,0 +1,361 @@
+package excel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unioffice/color"
+	"github.com/unidoc/unioffice/schema/soo/sml"
+	"github.com/unidoc/unioffice/spreadsheet"
+)
+
+// HAlign : horizontal alignment of a cell
+type HAlign int
+
+const (
+	HAlignGeneral HAlign = iota // numbers right, text left
+	HAlignLeft
+	HAlignCenter
+	HAlignRight
+	HAlignJustify
+)
+
+// VAlign : vertical alignment of a cell
+type VAlign int
+
+const (
+	VAlignDefault VAlign = iota // bottom
+	VAlignTop
+	VAlignMiddle
+	VAlignBottom
+)
+
+// BorderLine : line drawn on the four sides of a cell
+type BorderLine int
+
+const (
+	BorderNone BorderLine = iota
+	BorderThin
+	BorderMedium
+	BorderThick
+	BorderDashed
+	BorderDotted
+	BorderDouble
+)
+
+// Style : look of a cell, zero fields keep the default (or the current value when merged by SetCellStyle)
+// colours are "RRGGBB" or "#RRGGBB"
+// false cannot be told from unset, so Bold, Italic and Wrap are turned off by NoBold, NoItalic and NoWrap,
+// SetCellStyle(row, col, Style{NoBold: true}) un-bolds a cell and keeps the rest of its style
+type Style struct {
+	FontName  string
+	FontSize  float64
+	Bold      bool
+	NoBold    bool
+	Italic    bool
+	NoItalic  bool
+	FontColor string
+
+	FillColor string
+
+	Border      BorderLine
+	BorderColor string // black if empty
+
+	HAlign HAlign
+	VAlign VAlign
+	Wrap   bool
+	NoWrap bool
+
+	NumberFormat string // format code such as "#,##0.00" or "yyyy-mm-dd hh:mm", or a name such as "number", see FormatNumber
+}
+
+// merge : s with the non-zero fields of other, NoBold, NoItalic and NoWrap replace Bold, Italic and Wrap
+func (s Style) merge(other Style) Style {
+	if other.FontName != "" {
+		s.FontName = other.FontName
+	}
+	if other.FontSize != 0 {
+		s.FontSize = other.FontSize
+	}
+	if other.Bold || other.NoBold {
+		s.Bold, s.NoBold = other.Bold, other.NoBold
+	}
+	if other.Italic || other.NoItalic {
+		s.Italic, s.NoItalic = other.Italic, other.NoItalic
+	}
+	if other.FontColor != "" {
+		s.FontColor = other.FontColor
+	}
+	if other.FillColor != "" {
+		s.FillColor = other.FillColor
+	}
+	if other.Border != BorderNone {
+		s.Border = other.Border
+	}
+	if other.BorderColor != "" {
+		s.BorderColor = other.BorderColor
+	}
+	if other.HAlign != HAlignGeneral {
+		s.HAlign = other.HAlign
+	}
+	if other.VAlign != VAlignDefault {
+		s.VAlign = other.VAlign
+	}
+	if other.Wrap || other.NoWrap {
+		s.Wrap, s.NoWrap = other.Wrap, other.NoWrap
+	}
+	if other.NumberFormat != "" {
+		s.NumberFormat = other.NumberFormat
+	}
+	return s
+}
+
+// SetHeaderStyle : style of the header row written by WriteDataReader and WriteStructs, bold by default
+func (ec *ExcelControl) SetHeaderStyle(s Style) error {
+	if err := checkStyle(s); err != nil {
+		return ec.newError("SetHeaderStyle", "", err)
+	}
+	ec.m_HeaderStyle = s
+	return nil
+}
+
+// SetCellStyle : row = [1,n], col = [1,n] of the active sheet,
+// s is merged into the style the cell already has, so a date keeps its number format when it gets a fill
+func (ec *ExcelControl) SetCellStyle(row, col int, s Style) error {
+	sheet, cellID, err := ec.cellRef("SetCellStyle", row, col)
+	if err != nil {
+		return err
+	}
+	if err := checkStyle(s); err != nil {
+		return ec.newError("SetCellStyle", cellID, err)
+	}
+	ec.applyStyle(sheet.Cell(cellID), s)
+	return nil
+}
+
+// SetRangeStyle : SetCellStyle on every cell of "A1:D20" or "'Sheet 1'!A1:D20",
+// whole rows or columns ("A:C", "1:1") stop at the last used cell of the sheet
+func (ec *ExcelControl) SetRangeStyle(ref string, s Style) error {
+	rng, err := ParseCellRange(ref)
+	if err != nil {
+		return ec.newError("SetRangeStyle", ref, err)
+	}
+	return ec.SetCellRangeStyle(rng, s)
+}
+
+// SetCellRangeStyle : SetRangeStyle of a parsed range
+func (ec *ExcelControl) SetCellRangeStyle(rng CellRange, s Style) error {
+	sheet, err := ec.worksheet("SetRangeStyle")
+	if err != nil {
+		return err
+	}
+	if rng.Sheet != "" {
+		if sheet = ec.GetSheetByName(rng.Sheet); sheet == nil {
+			return ec.newError("SetRangeStyle", rng.String(), fmt.Errorf("no sheet named %q", rng.Sheet))
+		}
+	}
+	if err := checkStyle(s); err != nil {
+		return ec.newError("SetRangeStyle", rng.String(), err)
+	}
+	if rng.LastRow == MaxRow || rng.LastCol == MaxColumn {
+		used := usedRange(sheet)
+		if rng.LastRow == MaxRow {
+			rng.LastRow = maxInt(used.LastRow, rng.FirstRow)
+		}
+		if rng.LastCol == MaxColumn {
+			rng.LastCol = maxInt(used.LastCol, rng.FirstCol)
+		}
+	}
+	rng.Each(func(row, col int) bool {
+		ec.applyStyle(sheet.Cell(cellName(row, col)), s)
+		return true
+	})
+	return nil
+}
+
+// styleDef : what a style created by ExcelControl is made of,
+// base is the index of the style of an opened workbook it was built on, -1 if none
+type styleDef struct {
+	base  int64
+	style Style
+}
+
+// applyStyle : merge s into the style of cell,
+// a style of an opened workbook keeps its font, fill, border, alignment and number format where s has none
+func (ec *ExcelControl) applyStyle(cell spreadsheet.Cell, s Style) {
+	base := int64(-1)
+	if x := cell.X(); x != nil && x.SAttr != nil {
+		if def, ok := ec.m_StyleDefs[*x.SAttr]; ok {
+			base, s = def.base, def.style.merge(s)
+		} else if *x.SAttr != 0 {
+			base = int64(*x.SAttr)
+		}
+	}
+	cell.SetStyle(ec.styleOn(base, s))
+}
+
+// style : cell style for s, created once per workbook and shared by every cell that uses it
+func (ec *ExcelControl) style(s Style) spreadsheet.CellStyle {
+	return ec.styleOn(-1, s)
+}
+
+// styleOn : style with the fields of s set on a copy of the existing style base, see style
+func (ec *ExcelControl) styleOn(base int64, s Style) spreadsheet.CellStyle {
+	s.NumberFormat = resolveFormat(s.NumberFormat)
+	key := fmt.Sprintf("%d %#v", base, s)
+	if cs, ok := ec.m_Styles[key]; ok {
+		return cs
+	}
+
+	ss := ec.m_Workbook.StyleSheet
+	cs := ss.AddCellStyle()
+	var baseFont *sml.CT_Font
+	if base >= 0 {
+		if xf := ss.GetCellStyle(uint32(base)).X(); xf != nil {
+			copied := *xf
+			if xf.Alignment != nil {
+				alignment := *xf.Alignment
+				copied.Alignment = &alignment
+			}
+			*cs.X() = copied
+			baseFont = fontOf(ss, xf)
+		}
+	}
+	if s.FontName != "" || s.FontSize != 0 || s.Bold || s.NoBold || s.Italic || s.NoItalic || s.FontColor != "" {
+		font := ss.AddFont()
+		if baseFont != nil {
+			*font.X() = *baseFont
+		}
+		if s.FontName != "" {
+			font.SetName(s.FontName)
+		}
+		if s.FontSize != 0 {
+			font.SetSize(s.FontSize)
+		}
+		if s.Bold || s.NoBold {
+			font.SetBold(s.Bold)
+		}
+		if s.Italic || s.NoItalic {
+			font.SetItalic(s.Italic)
+		}
+		if s.FontColor != "" {
+			font.SetColor(parseColor(s.FontColor))
+		}
+		cs.SetFont(font)
+	}
+	if s.FillColor != "" {
+		fill := ss.Fills().AddFill()
+		pf := fill.SetPatternFill()
+		pf.SetPattern(sml.ST_PatternTypeSolid)
+		pf.SetFgColor(parseColor(s.FillColor))
+		cs.SetFill(fill)
+	}
+	if s.Border != BorderNone {
+		line := borderStyles[s.Border]
+		c := color.RGB(0, 0, 0)
+		if s.BorderColor != "" {
+			c = parseColor(s.BorderColor)
+		}
+		b := ss.AddBorder()
+		b.SetLeft(line, c)
+		b.SetRight(line, c)
+		b.SetTop(line, c)
+		b.SetBottom(line, c)
+		cs.SetBorder(b)
+	}
+	if s.HAlign != HAlignGeneral {
+		cs.SetHorizontalAlignment(hAligns[s.HAlign])
+	}
+	if s.VAlign != VAlignDefault {
+		cs.SetVerticalAlignment(vAligns[s.VAlign])
+	}
+	if s.Wrap || s.NoWrap {
+		cs.SetWrapped(s.Wrap)
+	}
+	if s.NumberFormat != "" {
+		cs.SetNumberFormat(s.NumberFormat)
+	}
+
+	ec.m_Styles[key] = cs
+	ec.m_StyleDefs[cs.Index()] = styleDef{base, s}
+	return cs
+}
+
+// fontOf : font of the cell format xf, nil if it has none
+func fontOf(ss spreadsheet.StyleSheet, xf *sml.CT_Xf) *sml.CT_Font {
+	fonts := ss.X().Fonts
+	if xf.FontIdAttr == nil || fonts == nil || int(*xf.FontIdAttr) >= len(fonts.Font) {
+		return nil
+	}
+	return fonts.Font[*xf.FontIdAttr]
+}
+
+// headerStyle : style of header cells, see SetHeaderStyle
+func (ec *ExcelControl) headerStyle() spreadsheet.CellStyle {
+	return ec.style(ec.m_HeaderStyle)
+}
+
+var borderStyles = map[BorderLine]sml.ST_BorderStyle{
+	BorderThin:   sml.ST_BorderStyleThin,
+	BorderMedium: sml.ST_BorderStyleMedium,
+	BorderThick:  sml.ST_BorderStyleThick,
+	BorderDashed: sml.ST_BorderStyleDashed,
+	BorderDotted: sml.ST_BorderStyleDotted,
+	BorderDouble: sml.ST_BorderStyleDouble,
+}
+
+var hAligns = map[HAlign]sml.ST_HorizontalAlignment{
+	HAlignLeft:    sml.ST_HorizontalAlignmentLeft,
+	HAlignCenter:  sml.ST_HorizontalAlignmentCenter,
+	HAlignRight:   sml.ST_HorizontalAlignmentRight,
+	HAlignJustify: sml.ST_HorizontalAlignmentJustify,
+}
+
+var vAligns = map[VAlign]sml.ST_VerticalAlignment{
+	VAlignTop:    sml.ST_VerticalAlignmentTop,
+	VAlignMiddle: sml.ST_VerticalAlignmentCenter,
+	VAlignBottom: sml.ST_VerticalAlignmentBottom,
+}
+
+// checkStyle : colours and enum values are valid, a flag is not both on and off
+func checkStyle(s Style) error {
+	if s.Bold && s.NoBold || s.Italic && s.NoItalic || s.Wrap && s.NoWrap {
+		return fmt.Errorf("bold, italic or wrap both on and off")
+	}
+	for _, c := range []string{s.FontColor, s.FillColor, s.BorderColor} {
+		if c != "" {
+			if _, err := parseHex(c); err != nil {
+				return err
+			}
+		}
+	}
+	if _, ok := borderStyles[s.Border]; !ok && s.Border != BorderNone {
+		return fmt.Errorf("invalid border %d", s.Border)
+	}
+	if _, ok := hAligns[s.HAlign]; !ok && s.HAlign != HAlignGeneral {
+		return fmt.Errorf("invalid horizontal alignment %d", s.HAlign)
+	}
+	if _, ok := vAligns[s.VAlign]; !ok && s.VAlign != VAlignDefault {
+		return fmt.Errorf("invalid vertical alignment %d", s.VAlign)
+	}
+	if s.FontSize < 0 || s.FontSize > 409 {
+		return fmt.Errorf("invalid font size %v", s.FontSize)
+	}
+	return nil
+}
+
+// parseHex : "#1F4E78" or "1F4E78" to its red, green and blue bytes
+func parseHex(s string) ([3]uint8, error) {
+	h := strings.TrimPrefix(s, "#")
+	n, err := strconv.ParseUint(h, 16, 32)
+	if len(h) != 6 || err != nil {
+		return [3]uint8{}, fmt.Errorf("invalid colour %q, want RRGGBB", s)
+	}
+	return [3]uint8{uint8(n >> 16), uint8(n >> 8), uint8(n)}, nil
+}
+
+func parseColor(s string) color.Color {
+	rgb, _ := parseHex(s) // checked by checkStyle
+	return color.RGB(rgb[0], rgb[1], rgb[2])
+}
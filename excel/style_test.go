@@ -0,0 +1,206 @@
+package excel
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/unidoc/unioffice/schema/soo/sml"
+)
+
+func styleAt(t *testing.T, ec *ExcelControl, ref string) Style {
+	x := ec.m_Worksheet.Cell(ref).X()
+	if x.SAttr == nil {
+		return Style{}
+	}
+	def, ok := ec.m_StyleDefs[*x.SAttr]
+	if !ok {
+		t.Fatalf("cell %s has a style not created by ExcelControl", ref)
+	}
+	return def.style
+}
+
+func TestSetRangeStyle(t *testing.T) {
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	band := Style{FillColor: "#DDEBF7", Border: BorderThin}
+	if err := ec.SetRangeStyle("A1:J100", band); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetRangeStyle("A1:J1", Style{Bold: true, FontColor: "1F4E78", HAlign: HAlignCenter, Wrap: true}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ec.m_Styles); n != 2 {
+		t.Errorf("styling 1000 cells created %d styles, want 2", n)
+	}
+
+	want := Style{Bold: true, FontColor: "1F4E78", FillColor: "#DDEBF7", Border: BorderThin, HAlign: HAlignCenter, Wrap: true}
+	if got := styleAt(t, ec, "C1"); got != want {
+		t.Errorf("style of C1 == %+v, want %+v", got, want)
+	}
+	if got := styleAt(t, ec, "J100"); got != band {
+		t.Errorf("style of J100 == %+v, want %+v", got, band)
+	}
+}
+
+func TestSetRangeStyleOff(t *testing.T) {
+	ec := openTemplate(t, func(ec *ExcelControl) {
+		if err := ec.SetRangeStyle("A1:C1", Style{Bold: true, Italic: true, Wrap: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err := ec.SetRangeStyle("A1:C2", Style{FillColor: "DDEBF7"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetRangeStyle("B1:C2", Style{NoBold: true, NoWrap: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ref          string
+		bold, italic bool
+		wrap         bool
+	}{
+		{"A1", true, true, true},
+		{"B1", false, true, false},
+		{"C2", false, false, false},
+	}
+	for _, c := range cases {
+		xf := xfAt(ec, c.ref)
+		font := fontOf(ec.m_Workbook.StyleSheet, xf)
+		bold := font != nil && font.B != nil && (font.B.ValAttr == nil || *font.B.ValAttr)
+		italic := font != nil && font.I != nil && (font.I.ValAttr == nil || *font.I.ValAttr)
+		wrap := xf.Alignment != nil && xf.Alignment.WrapTextAttr != nil && *xf.Alignment.WrapTextAttr
+		if bold != c.bold || italic != c.italic || wrap != c.wrap {
+			t.Errorf("%s bold, italic, wrap == %v, %v, %v, want %v, %v, %v", c.ref, bold, italic, wrap, c.bold, c.italic, c.wrap)
+		}
+	}
+	if got, want := styleAt(t, ec, "B1"), (Style{NoBold: true, FillColor: "DDEBF7", NoWrap: true}); got != want {
+		t.Errorf("style of B1 == %+v, want %+v", got, want)
+	}
+
+	if err := ec.SetCellStyle(1, 2, Style{Bold: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := styleAt(t, ec, "B1"), (Style{Bold: true, FillColor: "DDEBF7", NoWrap: true}); got != want {
+		t.Errorf("style of B1 bold again == %+v, want %+v", got, want)
+	}
+}
+
+func TestSetCellStyleKeepsNumberFormat(t *testing.T) {
+	created := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	reader := queryFake(t, sqltestDateRows(created))
+
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetHeaderStyle(Style{Bold: true, FillColor: "FFFF00"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ec.WriteDataReader(reader, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetCellStyle(2, 1, Style{FillColor: "FF0000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := styleAt(t, ec, "A1"); got != (Style{Bold: true, FillColor: "FFFF00"}) {
+		t.Errorf("header style == %+v", got)
+	}
+	if got, want := styleAt(t, ec, "A2"), (Style{FillColor: "FF0000", NumberFormat: "yyyy-mm-dd"}); got != want {
+		t.Errorf("style of A2 == %+v, want %+v", got, want)
+	}
+	if v, err := ec.ReadCell(2, 1); err != nil || v != created {
+		t.Errorf("ReadCell(2,1) == %v, %v, want %v", v, err, created)
+	}
+}
+
+func TestStyleErrors(t *testing.T) {
+	cases := []Style{
+		{FillColor: "red"},
+		{FontColor: "#12345"},
+		{Border: BorderLine(99)},
+		{HAlign: HAlign(-1)},
+		{FontSize: -1},
+		{Bold: true, NoBold: true},
+		{Wrap: true, NoWrap: true},
+	}
+
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		if err := ec.SetCellStyle(1, 1, c); err == nil {
+			t.Errorf("SetCellStyle(%+v) == nil, want error", c)
+		}
+	}
+	if err := ec.SetRangeStyle("A1:B", Style{Bold: true}); err == nil {
+		t.Errorf("SetRangeStyle(A1:B) == nil, want error")
+	}
+}
+
+// openTemplate : save a workbook styled by build and open it again, so its styles are not ExcelControl's
+func openTemplate(t *testing.T, build func(ec *ExcelControl)) *ExcelControl {
+	filename := filepath.Join(t.TempDir(), "template.xlsx")
+	template := New()
+	if err := template.OpenOrCreate(filename); err != nil {
+		t.Fatal(err)
+	}
+	build(template)
+	if err := template.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	ec := New()
+	if err := ec.OpenOrCreate(filename); err != nil {
+		t.Fatal(err)
+	}
+	return ec
+}
+
+func xfAt(ec *ExcelControl, ref string) *sml.CT_Xf {
+	return ec.m_Workbook.StyleSheet.GetCellStyle(*ec.m_Worksheet.Cell(ref).X().SAttr).X()
+}
+
+func TestSetCellStyleOnOpenedWorkbook(t *testing.T) {
+	created := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	ec := openTemplate(t, func(ec *ExcelControl) {
+		if err := ec.WriteRow([]interface{}{created}, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := ec.SetCellStyle(1, 1, Style{FontName: "Tahoma", Border: BorderThin, NumberFormat: "dd/mm/yyyy"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	template := *xfAt(ec, "A1")
+
+	if err := ec.SetCellStyle(1, 1, Style{FillColor: "FFFF00"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SetCellStyle(1, 1, Style{Bold: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	xf := xfAt(ec, "A1")
+	if !reflect.DeepEqual(xf.NumFmtIdAttr, template.NumFmtIdAttr) || !reflect.DeepEqual(xf.BorderIdAttr, template.BorderIdAttr) {
+		t.Errorf("number format and border of A1 not the template's")
+	}
+	if xf.FillIdAttr == nil || template.FillIdAttr != nil && *xf.FillIdAttr == *template.FillIdAttr {
+		t.Errorf("fill of A1 not set")
+	}
+	font := fontOf(ec.m_Workbook.StyleSheet, xf)
+	if font == nil || font.Name == nil || font.Name.ValAttr != "Tahoma" || font.B == nil {
+		t.Errorf("font of A1 == %+v, want bold Tahoma", font)
+	}
+	if got, want := styleAt(t, ec, "A1"), (Style{Bold: true, FillColor: "FFFF00"}); got != want {
+		t.Errorf("style of A1 == %+v, want %+v", got, want)
+	}
+	if v, err := ec.ReadCell(1, 1); err != nil || v != created {
+		t.Errorf("ReadCell(1,1) == %v, %v, want %v", v, err, created)
+	}
+}
@@ -0,0 +1,172 @@
+package excel
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/unidoc/unioffice/spreadsheet"
+)
+
+// ErrUnsupportedType : WriteRow got a value it cannot put in a cell and no SetUnsupportedTypeFunc handled it
+var ErrUnsupportedType = errors.New("unsupported value type")
+
+// SetNilText : text written for nil values and NULL columns, empty (the default) leaves the cell blank
+func (ec *ExcelControl) SetNilText(text string) {
+	ec.m_NilText = text
+}
+
+// SetUnsupportedTypeFunc : convert values WriteRow cannot write into one it can,
+// returning an error stops the write, nil fn restores the default ErrUnsupportedType
+func (ec *ExcelControl) SetUnsupportedTypeFunc(fn func(value interface{}) (interface{}, error)) {
+	ec.m_Unsupported = fn
+}
+
+// setCellValue : typed setter, see WriteRow for the supported values
+// return the value written: nil, bool, time.Time, string or float64
+func (ec *ExcelControl) setCellValue(cell spreadsheet.Cell, value interface{}) (interface{}, error) {
+	v, err := ec.cellData(value, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch s2 := v.(type) {
+	case nil:
+		if ec.m_NilText == "" {
+			cell.Clear()
+		} else {
+			cell.SetString(ec.m_NilText)
+		}
+	case bool:
+		cell.SetBool(s2)
+	case time.Time:
+		cell.SetTime(s2)
+	case string:
+		cell.SetString(s2)
+	case float64:
+		cell.SetNumber(s2)
+	}
+	return v, nil
+}
+
+// cellData : reduce value to nil, bool, time.Time, string or float64,
+// integers beyond 2^53 become text so no digit is lost
+func (ec *ExcelControl) cellData(value interface{}, depth int) (interface{}, error) {
+	if depth > 8 {
+		return nil, fmt.Errorf("%w %T: too many levels of conversion", ErrUnsupportedType, value)
+	}
+
+	switch s2 := value.(type) {
+	case nil, bool, string:
+		return value, nil
+	case time.Time:
+		if s2.IsZero() {
+			return nil, nil // year 1 is before the sheet epoch
+		}
+		return s2, nil
+	case float64:
+		return checkFloat(s2)
+	case float32:
+		// through the shortest decimal so float32(0.1) is written as 0.1
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(s2), 'g', -1, 32), 64)
+		return checkFloat(f)
+	case int:
+		return intData(int64(s2)), nil
+	case int8:
+		return float64(s2), nil
+	case int16:
+		return float64(s2), nil
+	case int32:
+		return float64(s2), nil
+	case int64:
+		return intData(s2), nil
+	case uint:
+		return uintData(uint64(s2)), nil
+	case uint8:
+		return float64(s2), nil
+	case uint16:
+		return float64(s2), nil
+	case uint32:
+		return float64(s2), nil
+	case uint64:
+		return uintData(s2), nil
+	case []byte:
+		if utf8.Valid(s2) {
+			return string(s2), nil
+		}
+	case driver.Valuer: // sql.NullString, sql.NullInt64, ... and custom column types
+		if rv := reflect.ValueOf(s2); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, nil
+		}
+		v, err := s2.Value()
+		if err != nil {
+			return nil, err
+		}
+		return ec.cellData(v, depth+1)
+	case fmt.Stringer:
+		if rv := reflect.ValueOf(s2); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, nil
+		}
+		return s2.String(), nil
+	}
+
+	// pointers and named types such as type Status int
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return ec.cellData(rv.Elem().Interface(), depth+1)
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intData(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintData(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return checkFloat(rv.Float())
+	}
+
+	if ec.m_Unsupported != nil {
+		v, err := ec.m_Unsupported(value)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.TypeOf(v) == reflect.TypeOf(value) {
+			return nil, fmt.Errorf("%w %T: unsupported type func returned the same type", ErrUnsupportedType, value)
+		}
+		return ec.cellData(v, depth+1)
+	}
+	return nil, fmt.Errorf("%w %T", ErrUnsupportedType, value)
+}
+
+// maxExactInt : integers beyond this do not fit the float64 a cell holds and are written as text
+const maxExactInt = 1 << 53
+
+func intData(v int64) interface{} {
+	if v > maxExactInt || v < -maxExactInt {
+		return strconv.FormatInt(v, 10)
+	}
+	return float64(v)
+}
+
+func uintData(v uint64) interface{} {
+	if v > maxExactInt {
+		return strconv.FormatUint(v, 10)
+	}
+	return float64(v)
+}
+
+func checkFloat(f float64) (interface{}, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("%w: %v cannot be stored in a cell", ErrUnsupportedType, f)
+	}
+	return f, nil
+}
@@ -0,0 +1,118 @@
+package excel
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type status int
+
+type money struct{ satang int64 }
+
+func (m money) String() string { return fmt.Sprintf("%d.%02d", m.satang/100, m.satang%100) }
+
+type point struct{ x, y int }
+
+func TestWriteRowTypes(t *testing.T) {
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	name := "Somchai"
+	var nilName *string
+	cases := []struct {
+		in   interface{}
+		want interface{}
+	}{
+		{int(-3), -3.0},
+		{int8(8), 8.0},
+		{int16(16), 16.0},
+		{uint(7), 7.0},
+		{uint8(255), 255.0},
+		{uint64(1 << 40), float64(1 << 40)},
+		{int64(1 << 53), float64(1 << 53)},
+		{int64(-1 << 53), float64(-1 << 53)},
+		{int64(1<<53 + 1), "9007199254740993"},
+		{int64(math.MinInt64), "-9223372036854775808"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{status(1<<53 + 1), "9007199254740993"},
+		{float32(0.1), 0.1},
+		{[]byte("สวัสดี"), "สวัสดี"},
+		{&name, "Somchai"},
+		{nilName, nil},
+		{status(2), 2.0},
+		{sql.NullString{String: "x", Valid: true}, "x"},
+		{sql.NullString{}, nil},
+		{sql.NullInt64{Int64: 42, Valid: true}, 42.0},
+		{sql.NullFloat64{Float64: 1.5, Valid: true}, 1.5},
+		{sql.NullBool{Bool: true, Valid: true}, true},
+		{sql.NullTime{Time: day, Valid: true}, day},
+		{&sql.NullInt32{Int32: 5, Valid: true}, 5.0},
+		{money{12345}, "123.45"},
+		{time.Time{}, nil},
+		{nil, nil},
+	}
+
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range cases {
+		if err := ec.WriteRow([]interface{}{c.in}, i+1, 1); err != nil {
+			t.Errorf("WriteRow(%#v) error %v", c.in, err)
+			continue
+		}
+		got, err := ec.ReadCell(i+1, 1)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("WriteRow(%#v) wrote %#v, want %#v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestWriteRowNilText(t *testing.T) {
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	ec.SetNilText("-")
+	if err := ec.WriteRow([]interface{}{nil, sql.NullInt64{}, "a"}, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ec.ReadRow(1)
+	if want := []interface{}{"-", "-", "a"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadRow(1) == %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestWriteRowUnsupported(t *testing.T) {
+	ec := New()
+	if err := ec.OpenOrCreate("test.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, in := range []interface{}{point{1, 2}, math.NaN(), []byte{0xff, 0xfe}, make(chan int)} {
+		err := ec.WriteRow([]interface{}{"a", in}, 1, 1)
+		var e *Error
+		if !errors.Is(err, ErrUnsupportedType) || !errors.As(err, &e) || e.Cell != "B1" {
+			t.Errorf("WriteRow(%#v) == %v, want ErrUnsupportedType at B1", in, err)
+		}
+	}
+
+	ec.SetUnsupportedTypeFunc(func(value interface{}) (interface{}, error) {
+		if p, ok := value.(point); ok {
+			return fmt.Sprintf("(%d,%d)", p.x, p.y), nil
+		}
+		return nil, fmt.Errorf("cannot write %T", value)
+	})
+	if err := ec.WriteRow([]interface{}{point{1, 2}}, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ec.ReadCell(2, 1); got != "(1,2)" {
+		t.Errorf("WriteRow(point) with unsupported type func wrote %#v, want %q", got, "(1,2)")
+	}
+	if err := ec.WriteRow([]interface{}{make(chan int)}, 3, 1); err == nil {
+		t.Errorf("WriteRow(chan) with unsupported type func == nil, want error")
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/churikawit/gosrc
 
-go 1.15
+go 1.16
 
 require (
 	github.com/denisenkom/go-mssqldb v0.10.0
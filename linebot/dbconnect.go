@@ -1,7 +1,6 @@
 package linebot
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/churikawit/gosrc/sql"
@@ -23,27 +22,19 @@ func RegisterUser(user_id string, bot_id int, display_name, picture_url, status_
 	Conn.OpenConnection()
 	defer Conn.CloseConnection()
 
-	queryString := "select count(*) from register_user where user_id=$1 and bot_id=$2"
-	var reader *sql.DataReader = Conn.Query(queryString, user_id, bot_id)
-	defer reader.Close()
-
-	reader.Read()
-	datacell := reader.GetValue2(0)
-	s, ok := datacell.(int32)
-	if !ok {
-		fmt.Printf("RegisterUser: get count(*) error\n")
-	}
-
-	if s <= 0 {
-		insert_script := `insert into register_user(user_id, bot_id, display_name, picture_url, status_message, create_time) 
-			values($1,$2,$3,$4,$5,$6)`
-		Conn.Query(insert_script, user_id, bot_id, display_name, picture_url, status_message, getCurrentTime())
-	} else {
-		// update
-		update_script := `update register_user
-			set display_name = $1, picture_url = $2, status_message = $3
-			where user_id=$4 and bot_id=$5`
-		Conn.Query(update_script, display_name, picture_url, status_message, user_id, bot_id)
+	// one statement, so two webhook events of the same user cannot both insert
+	// create_time is written on insert and kept on update
+	_, err := Conn.Upsert2("register_user", []string{"user_id", "bot_id"}, map[string]interface{}{
+		"user_id":        user_id,
+		"bot_id":         bot_id,
+		"display_name":   display_name,
+		"picture_url":    picture_url,
+		"status_message": status_message,
+	}, map[string]interface{}{
+		"create_time": getCurrentTime(),
+	})
+	if err != nil {
+		sql.DefaultLogger().Log(sql.LevelError, "RegisterUser failed", "user_id", user_id, "bot_id", bot_id, "error", err)
 	}
 }
 
@@ -59,7 +50,7 @@ func GetIntentStage(user_id string, bot_id int) string {
 		datacell := reader.GetValue2(0)
 		s, ok := datacell.(string)
 		if !ok {
-			fmt.Printf("RegisterUser: get count(*) error\n")
+			sql.DefaultLogger().Log(sql.LevelError, "GetIntentStage: unexpected intent_stage", "value", datacell)
 			return ""
 		}
 		return s
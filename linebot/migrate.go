@@ -0,0 +1,26 @@
+package linebot
+
+import (
+	"embed"
+	"io/fs"
+
+	"github.com/churikawit/gosrc/sql"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrate : create or upgrade the register_user and log_event tables, return number of migrations applied
+func Migrate() (int, error) {
+	err := Conn.OpenConnection()
+	if err != nil {
+		return 0, err
+	}
+	defer Conn.CloseConnection()
+
+	source, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return 0, err
+	}
+	return sql.NewMigrator(Conn, source).Migrate()
+}
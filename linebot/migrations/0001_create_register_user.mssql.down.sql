@@ -0,0 +1 @@
+drop table register_user;
@@ -0,0 +1,10 @@
+create table register_user (
+	user_id        nvarchar(64)  not null,
+	bot_id         int           not null,
+	display_name   nvarchar(255),
+	picture_url    nvarchar(max),
+	status_message nvarchar(max),
+	intent_stage   nvarchar(255),
+	create_time    datetime2     not null default sysdatetime(),
+	primary key (user_id, bot_id)
+);
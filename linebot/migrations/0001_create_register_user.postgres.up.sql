@@ -0,0 +1,10 @@
+create table register_user (
+	user_id        varchar(64)  not null,
+	bot_id         int          not null,
+	display_name   varchar(255),
+	picture_url    text,
+	status_message text,
+	intent_stage   varchar(255),
+	create_time    timestamp    not null default now(),
+	primary key (user_id, bot_id)
+);
@@ -0,0 +1,13 @@
+create table log_event (
+	id             bigint        identity(1,1) primary key,
+	source_type    nvarchar(16),
+	source_userid  nvarchar(64),
+	source_groupid nvarchar(64),
+	source_roomid  nvarchar(64),
+	bot_id         int           not null,
+	event_type     nvarchar(32),
+	event_body     nvarchar(max),
+	create_time    datetime2     not null default sysdatetime()
+);
+
+create index ix_log_event_bot_time on log_event (bot_id, create_time);
@@ -0,0 +1 @@
+drop table log_event;
@@ -0,0 +1,13 @@
+create table log_event (
+	id             bigserial    primary key,
+	source_type    varchar(16),
+	source_userid  varchar(64),
+	source_groupid varchar(64),
+	source_roomid  varchar(64),
+	bot_id         int          not null,
+	event_type     varchar(32),
+	event_body     text,
+	create_time    timestamp    not null default now()
+);
+
+create index ix_log_event_bot_time on log_event (bot_id, create_time);
@@ -0,0 +1,195 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RowSource : forward-only source of rows, *DataReader satisfies it
+type RowSource interface {
+	Read() bool
+	GetValues() []interface{}
+}
+
+// maximum number of parameters per statement accepted by the server
+// SQL Server allows 2100 but sp_executesql takes 2 of them for the statement and its parameter list
+const (
+	mssqlMaxParams    = 2098
+	postgresMaxParams = 65535
+	mssqlMaxValueRows = 1000 // a VALUES list of SQL Server holds at most 1000 rows
+)
+
+// BatchInsertOptions : options of BatchInsert and BatchInsertFrom
+type BatchInsertOptions struct {
+	// BatchSize is the number of rows per INSERT, 0 means as many as the parameter limit allows
+	BatchSize int
+	// NoTransaction commits every batch on its own instead of running all batches in one transaction
+	NoTransaction bool
+	// Progress is called after every batch with the batch number [1,n] and the total rows inserted so far
+	Progress func(batch int, rows int64)
+}
+
+// BatchInsertError : a batch failed, rows are counted from 1 in the order they were read
+type BatchInsertError struct {
+	Batch    int
+	FirstRow int64
+	LastRow  int64
+	Err      error
+}
+
+func (e *BatchInsertError) Error() string {
+	return fmt.Sprintf("batch insert: batch %d (row %d-%d): %v", e.Batch, e.FirstRow, e.LastRow, e.Err)
+}
+
+func (e *BatchInsertError) Unwrap() error {
+	return e.Err
+}
+
+// BatchInsert : insert rows with multi-row INSERT ... VALUES statements, return number of rows inserted
+// every row must have one value per column
+func (bc *baseConnector) BatchInsert(table string, columns []string, rows [][]interface{}, opts BatchInsertOptions) (int64, error) {
+	return bc.BatchInsertFrom(table, columns, &sliceSource{rows: rows}, opts)
+}
+
+// BatchInsertFrom : same as BatchInsert, rows are read from src e.g. a DataReader of another connector
+func (bc *baseConnector) BatchInsertFrom(table string, columns []string, src RowSource, opts BatchInsertOptions) (int64, error) {
+	if opts.NoTransaction {
+		return batchInsert(bc, table, columns, src, opts)
+	}
+	var count int64
+	err := bc.InTransaction(func(tx *Transaction) error {
+		var err error
+		count, err = batchInsert(tx, table, columns, src, opts)
+		return err
+	})
+	if err != nil {
+		return 0, err // rolled back
+	}
+	return count, nil
+}
+
+// BatchInsert : same as the connector BatchInsert, inside the transaction
+func (t *Transaction) BatchInsert(table string, columns []string, rows [][]interface{}, opts BatchInsertOptions) (int64, error) {
+	return batchInsert(t, table, columns, &sliceSource{rows: rows}, opts)
+}
+
+// BatchInsertFrom : same as the connector BatchInsertFrom, inside the transaction
+func (t *Transaction) BatchInsertFrom(table string, columns []string, src RowSource, opts BatchInsertOptions) (int64, error) {
+	return batchInsert(t, table, columns, src, opts)
+}
+
+func batchInsert(e executor, table string, columns []string, src RowSource, opts BatchInsertOptions) (int64, error) {
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("batch insert %s: no columns", table)
+	}
+	size := batchRows(e.Dialect(), len(columns), opts.BatchSize)
+	if size <= 0 {
+		return 0, fmt.Errorf("batch insert %s: %d columns exceed the parameter limit of %s", table, len(columns), e.Dialect())
+	}
+
+	var total int64
+	batch := 0
+	args := make([]interface{}, 0, size*len(columns))
+	pending := 0
+
+	flush := func() error {
+		if pending == 0 {
+			return nil
+		}
+		batch++
+		_, err := e.exec(buildInsert(e.Dialect(), table, columns, pending), args...)
+		if err != nil {
+			return &BatchInsertError{Batch: batch, FirstRow: total + 1, LastRow: total + int64(pending), Err: err}
+		}
+		total += int64(pending)
+		args, pending = args[:0], 0
+		if opts.Progress != nil {
+			opts.Progress(batch, total)
+		}
+		return nil
+	}
+
+	for src.Read() {
+		values := src.GetValues()
+		if len(values) != len(columns) {
+			return total, fmt.Errorf("batch insert %s: row %d has %d values, want %d",
+				table, total+int64(pending)+1, len(values), len(columns))
+		}
+		args = append(args, values...)
+		pending++
+		if pending == size {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	if r, ok := src.(interface{ Err() error }); ok {
+		if err := r.Err(); err != nil {
+			return total, fmt.Errorf("batch insert %s: read source: %w", table, err)
+		}
+	}
+	if err := flush(); err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+// batchRows : number of rows per statement allowed by the dialect
+func batchRows(d Dialect, columnCount, batchSize int) int {
+	size := postgresMaxParams / columnCount
+	if d == DialectMssql {
+		size = mssqlMaxParams / columnCount
+		if size > mssqlMaxValueRows {
+			size = mssqlMaxValueRows
+		}
+	}
+	if batchSize > 0 && batchSize < size {
+		size = batchSize
+	}
+	return size
+}
+
+// buildInsert : INSERT INTO table (columns) VALUES (...), (...) with rowCount rows of parameters
+func buildInsert(d Dialect, table string, columns []string, rowCount int) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = d.QuoteIdent(c)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES ", d.QuoteIdent(table), strings.Join(quoted, ", "))
+	n := 1
+	for r := 0; r < rowCount; r++ {
+		if r > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('(')
+		for c := range columns {
+			if c > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(d.Placeholder(n))
+			n++
+		}
+		sb.WriteByte(')')
+	}
+	return sb.String()
+}
+
+// sliceSource : RowSource over an in-memory slice
+type sliceSource struct {
+	rows [][]interface{}
+	pos  int
+}
+
+func (s *sliceSource) Read() bool {
+	if s.pos >= len(s.rows) {
+		return false
+	}
+	s.pos++
+	return true
+}
+
+func (s *sliceSource) GetValues() []interface{} {
+	return s.rows[s.pos-1]
+}
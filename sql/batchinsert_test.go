@@ -0,0 +1,40 @@
+package sql
+
+import "testing"
+
+func TestBatchRows(t *testing.T) {
+	cases := []struct {
+		dialect   Dialect
+		columns   int
+		batchSize int
+		want      int
+	}{
+		{DialectMssql, 1, 0, 1000},
+		{DialectMssql, 7, 0, 299}, // 7 x 300 = 2100 parameters is over the limit
+		{DialectMssql, 2098, 0, 1},
+		{DialectMssql, 2099, 0, 0},
+		{DialectMssql, 7, 50, 50},
+		{DialectMssql, 3000, 0, 0},
+		{DialectPostgres, 7, 0, 9362},
+		{DialectPostgres, 7, 100000, 9362},
+	}
+	for _, c := range cases {
+		got := batchRows(c.dialect, c.columns, c.batchSize)
+		if got != c.want {
+			t.Errorf("batchRows(%s, %d, %d) == %d, want %d", c.dialect, c.columns, c.batchSize, got, c.want)
+		}
+	}
+}
+
+func TestBuildInsert(t *testing.T) {
+	got := buildInsert(DialectPostgres, "log_event", []string{"bot_id", "event_type"}, 2)
+	want := `INSERT INTO "log_event" ("bot_id", "event_type") VALUES ($1, $2), ($3, $4)`
+	if got != want {
+		t.Errorf("buildInsert(postgres) == %q, want %q", got, want)
+	}
+	got = buildInsert(DialectMssql, "dbo.log_event", []string{"bot_id"}, 3)
+	want = `INSERT INTO [dbo].[log_event] ([bot_id]) VALUES (@p1), (@p2), (@p3)`
+	if got != want {
+		t.Errorf("buildInsert(mssql) == %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,237 @@
+package sql
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStore : storage of cached query results, must be safe for concurrent use
+type CacheStore interface {
+	Get(key string) (*DataTable, bool)
+	Set(key string, table *DataTable, ttl time.Duration, tags []string)
+	Delete(key string)
+	// InvalidateTag : drop every entry stored with tag, return number of entries dropped
+	InvalidateTag(tag string) int
+	Purge()
+}
+
+// QueryCache : cache in front of a connector's Query, keyed by Namespace, SQL text and arguments
+//
+//	cache := sql.NewQueryCache(conn, sql.NewLRUStore(500), 10*time.Second)
+//	reader := cache.Query("select ... where branch=$1", branch)
+//	...
+//	cache.Invalidate("sales") // after writing sales rows
+type QueryCache struct {
+	Conn  DbConnector
+	Store CacheStore
+	TTL   time.Duration // default time to live of Query
+	// Namespace keeps the results of different databases apart in a shared Store,
+	// empty uses the identity of Conn, set it (e.g. to the database name) to share results across processes
+	Namespace string
+
+	hits   uint64
+	misses uint64
+}
+
+func NewQueryCache(conn DbConnector, store CacheStore, ttl time.Duration) *QueryCache {
+	return &QueryCache{Conn: conn, Store: store, TTL: ttl}
+}
+
+// Query : cached result with the default TTL as a DataReader, return nil if error
+func (qc *QueryCache) Query(QueryString string, args ...interface{}) *DataReader {
+	table, err := qc.QueryTable(qc.TTL, nil, QueryString, args...)
+	if err != nil {
+		return nil
+	}
+	return table.Reader()
+}
+
+// QueryTable : cached result stored for ttl under tags, the table is shared between callers and must not be modified
+func (qc *QueryCache) QueryTable(ttl time.Duration, tags []string, QueryString string, args ...interface{}) (*DataTable, error) {
+	key := qc.key(QueryString, args)
+	if table, ok := qc.Store.Get(key); ok {
+		atomic.AddUint64(&qc.hits, 1)
+		return table, nil
+	}
+	atomic.AddUint64(&qc.misses, 1)
+
+	reader, err := queryConn(qc.Conn, QueryString, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cache: %w", err)
+	}
+	table, err := LoadDataTable(reader)
+	if err != nil {
+		return nil, fmt.Errorf("cache: %w", err)
+	}
+	qc.Store.Set(key, table, ttl, tags)
+	return table, nil
+}
+
+// Invalidate : drop all cached results stored under any of tags
+func (qc *QueryCache) Invalidate(tags ...string) int {
+	count := 0
+	for _, tag := range tags {
+		count += qc.Store.InvalidateTag(tag)
+	}
+	return count
+}
+
+// InvalidateQuery : drop the cached result of one query
+func (qc *QueryCache) InvalidateQuery(QueryString string, args ...interface{}) {
+	qc.Store.Delete(qc.key(QueryString, args))
+}
+
+func (qc *QueryCache) key(QueryString string, args []interface{}) string {
+	namespace := qc.Namespace
+	if namespace == "" {
+		namespace = fmt.Sprintf("%p", qc.Conn)
+	}
+	return CacheKey(namespace, qc.Conn.Dialect(), QueryString, args...)
+}
+
+// Stats : number of cache hits and misses so far
+func (qc *QueryCache) Stats() (hits, misses uint64) {
+	return atomic.LoadUint64(&qc.hits), atomic.LoadUint64(&qc.misses)
+}
+
+// CacheKey : key of a query in a CacheStore, arguments are compared by type and value,
+// namespace identifies the database, see QueryCache.Namespace
+func CacheKey(namespace string, d Dialect, QueryString string, args ...interface{}) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", namespace, d, QueryString)
+	for _, a := range args {
+		if t, ok := a.(time.Time); ok {
+			a = t.UTC().Format(time.RFC3339Nano)
+		}
+		fmt.Fprintf(h, "\x00%T:%v", a, a)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+type lruEntry struct {
+	key     string
+	table   *DataTable
+	expires time.Time
+	tags    []string
+}
+
+// LRUStore : in-memory CacheStore holding at most Capacity entries, least recently used entries are dropped first
+type LRUStore struct {
+	capacity int
+
+	mu    sync.Mutex
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+	now   func() time.Time
+}
+
+func NewLRUStore(capacity int) *LRUStore {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &LRUStore{
+		capacity: capacity,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+		tags:     map[string]map[string]struct{}{},
+		now:      time.Now,
+	}
+}
+
+func (s *LRUStore) Get(key string) (*DataTable, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if !entry.expires.IsZero() && !s.now().Before(entry.expires) {
+		s.remove(el)
+		return nil, false
+	}
+	s.order.MoveToFront(el)
+	return entry.table, true
+}
+
+// Set : store table, ttl <= 0 keeps it until evicted or invalidated
+func (s *LRUStore) Set(key string, table *DataTable, ttl time.Duration, tags []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	entry := &lruEntry{key: key, table: table, tags: tags}
+	if ttl > 0 {
+		entry.expires = s.now().Add(ttl)
+	}
+	s.items[key] = s.order.PushFront(entry)
+	for _, tag := range tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = map[string]struct{}{}
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *LRUStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+}
+
+func (s *LRUStore) InvalidateTag(tag string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for key := range s.tags[tag] {
+		if el, ok := s.items[key]; ok {
+			s.remove(el)
+			count++
+		}
+	}
+	delete(s.tags, tag)
+	return count
+}
+
+func (s *LRUStore) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.order.Init()
+	s.items = map[string]*list.Element{}
+	s.tags = map[string]map[string]struct{}{}
+}
+
+// Len : number of entries, expired entries included until they are touched
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *LRUStore) remove(el *list.Element) {
+	entry := el.Value.(*lruEntry)
+	s.order.Remove(el)
+	delete(s.items, entry.key)
+	for _, tag := range entry.tags {
+		if keys := s.tags[tag]; keys != nil {
+			delete(keys, entry.key)
+			if len(keys) == 0 {
+				delete(s.tags, tag)
+			}
+		}
+	}
+}
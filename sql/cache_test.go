@@ -0,0 +1,171 @@
+package sql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/churikawit/gosrc/sql/sqltest"
+)
+
+func TestLRUStore(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := NewLRUStore(2)
+	s.now = func() time.Time { return now }
+	table := &DataTable{}
+
+	s.Set("a", table, time.Minute, []string{"sales"})
+	s.Set("b", table, 0, []string{"sales", "stock"})
+	s.Get("a")                // a is now most recently used
+	s.Set("c", table, 0, nil) // evicts b
+	if _, ok := s.Get("b"); ok {
+		t.Errorf("Get(b) after eviction expect miss")
+	}
+	if _, ok := s.Get("a"); !ok {
+		t.Errorf("Get(a) expect hit")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := s.Get("a"); ok {
+		t.Errorf("Get(a) after ttl expect miss")
+	}
+
+	s.Set("d", table, 0, []string{"stock"})
+	if n := s.InvalidateTag("stock"); n != 1 {
+		t.Errorf("InvalidateTag(stock) == %d, want 1", n)
+	}
+	if _, ok := s.Get("d"); ok {
+		t.Errorf("Get(d) after invalidate expect miss")
+	}
+	if s.Len() != 1 {
+		t.Errorf("Len() == %d, want 1", s.Len())
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	q := "select * from sales where branch=$1"
+	if CacheKey("sales", DialectPostgres, q, 1) == CacheKey("sales", DialectPostgres, q, "1") {
+		t.Errorf("CacheKey must differ by argument type")
+	}
+	if CacheKey("sales", DialectPostgres, q, 1) == CacheKey("stock", DialectPostgres, q, 1) {
+		t.Errorf("CacheKey must differ by namespace")
+	}
+	if CacheKey("sales", DialectPostgres, q, 1) != CacheKey("sales", DialectPostgres, q, 1) {
+		t.Errorf("CacheKey must be stable")
+	}
+}
+
+// openCached : connector on a fake database with a cache using store
+func openCached(t *testing.T, store CacheStore) (*QueryCache, *sqltest.DB) {
+	fake := sqltest.New()
+	conn := NewPostgresConnector2(fake.DSN())
+	conn.DriverName = sqltest.DriverName
+	conn.SetLogger(NopLogger())
+	if err := conn.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.CloseConnection)
+	return NewQueryCache(conn, store, time.Minute), fake
+}
+
+func branchRows(names ...string) *sqltest.Rows {
+	rows := sqltest.NewRows(sqltest.Column{Name: "name", TypeName: "VARCHAR"})
+	for _, n := range names {
+		rows.AddRow(n)
+	}
+	return rows
+}
+
+func queryCount(fake *sqltest.DB) int {
+	n := 0
+	for _, call := range fake.Calls() {
+		if call.Kind == "query" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestQueryCache(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := NewLRUStore(10)
+	store.now = func() time.Time { return now }
+	qc, fake := openCached(t, store)
+	q := "select name from branch where region=$1"
+	fake.ExpectQuery(q).WithArgs("north").WillReturnRows(branchRows("BKK", "CNX")).Times(2)
+	fake.ExpectQuery(q).WithArgs("south").WillReturnRows(branchRows("HKT")).Times(2)
+
+	// a hit skips the driver
+	for i := 0; i < 2; i++ {
+		table, err := qc.QueryTable(time.Minute, []string{"branch"}, q, "north")
+		if err != nil || table.RowCount() != 2 {
+			t.Fatalf("QueryTable(north) == %v, %v", table, err)
+		}
+	}
+	if n := queryCount(fake); n != 1 {
+		t.Errorf("driver ran %d queries for 2 calls, want 1", n)
+	}
+	if hits, misses := qc.Stats(); hits != 1 || misses != 1 {
+		t.Errorf("Stats() == %d, %d, want 1 hit and 1 miss", hits, misses)
+	}
+
+	// the ttl ran out
+	now = now.Add(2 * time.Minute)
+	qc.QueryTable(time.Minute, []string{"branch"}, q, "north")
+	if n := queryCount(fake); n != 2 {
+		t.Errorf("driver ran %d queries after the ttl, want 2", n)
+	}
+
+	// Invalidate drops the tagged entries only
+	qc.QueryTable(0, nil, q, "south")
+	if n := qc.Invalidate("branch"); n != 1 {
+		t.Errorf("Invalidate(branch) == %d, want 1", n)
+	}
+	qc.QueryTable(0, nil, q, "south")
+	if n := queryCount(fake); n != 3 {
+		t.Errorf("driver ran %d queries, want 3 with south still cached", n)
+	}
+	qc.InvalidateQuery(q, "south")
+	if reader := qc.Query(q, "south"); reader == nil || !reader.Read() || reader.GetValue2(0) != "HKT" {
+		t.Errorf("Query(south) after InvalidateQuery did not return HKT")
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQueryCacheError(t *testing.T) {
+	qc, fake := openCached(t, NewLRUStore(10))
+	errTimeout := errors.New("canceling statement due to statement timeout")
+	fake.ExpectQuery("select name from branch").WillReturnError(errTimeout)
+	fake.ExpectQuery("select name from branch").WillReturnRows(branchRows("BKK"))
+
+	if _, err := qc.QueryTable(time.Minute, nil, "select name from branch"); !errors.Is(err, errTimeout) {
+		t.Errorf("QueryTable error == %v, want the driver error", err)
+	}
+	table, err := qc.QueryTable(time.Minute, nil, "select name from branch")
+	if err != nil || table.RowCount() != 1 {
+		t.Errorf("QueryTable after an error == %v, %v, want the rows", table, err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQueryCacheSharedStore(t *testing.T) {
+	store := NewLRUStore(10)
+	primary, primaryFake := openCached(t, store)
+	replica, replicaFake := openCached(t, store)
+	primaryFake.ExpectQuery("select name from branch").WillReturnRows(branchRows("BKK"))
+	replicaFake.ExpectQuery("select name from branch").WillReturnRows(branchRows("CNX"))
+
+	for qc, want := range map[*QueryCache]string{primary: "BKK", replica: "CNX"} {
+		table, err := qc.QueryTable(time.Minute, nil, "select name from branch")
+		if err != nil || table.Rows[0][0] != want {
+			t.Errorf("QueryTable == %v, %v, want %s", table, err, want)
+		}
+	}
+	if store.Len() != 2 {
+		t.Errorf("store holds %d entries, want one per connector", store.Len())
+	}
+}
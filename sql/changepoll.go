@@ -0,0 +1,428 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatermarkKind : type of the watermark column of a ChangePoller
+type WatermarkKind int
+
+const (
+	WatermarkNumber     WatermarkKind = iota // identity or any increasing integer
+	WatermarkRowVersion                      // rowversion / timestamp, rows of open transactions wait until they commit
+	WatermarkTime                            // datetime, datetime2 e.g. updated_at, compared in the column's own type
+)
+
+// ErrChangeTrackingExpired : the saved change tracking version is older than the retention period,
+// changes were lost and the consumer must reload the table
+var ErrChangeTrackingExpired = errors.New("sql: change tracking version is no longer valid")
+
+const watermarkAlias = "__watermark"
+
+// ChangePoller : poll a SQL Server table for new or changed rows, without triggers
+//
+// watermark mode reads rows with WatermarkColumn greater than the saved watermark, in order, BatchSize rows at a time,
+// rows sharing a watermark value always go in the same batch so a batch never ends partway through them.
+// change tracking mode reads CHANGETABLE(CHANGES ...) and needs change tracking enabled on the database and table,
+// every row carries change_operation (I, U or D) and change_version, deleted rows only have their key columns.
+// the watermark is saved to Store after the handler accepts a batch, so a restart resumes where it stopped
+type ChangePoller struct {
+	Table           string
+	Columns         []string      // columns to read, empty means all
+	WatermarkColumn string        // watermark mode
+	WatermarkKind   WatermarkKind // watermark mode
+	KeyColumns      []string      // change tracking mode, primary key of Table
+	ChangeTracking  bool
+	Interval        time.Duration // time between polls, default 5s
+	BatchSize       int           // rows per batch in watermark mode, default 1000
+	Store           WatermarkStore
+	StoreKey        string // key in Store, default Table + "." + WatermarkColumn or Table + ".ct"
+
+	conn          *MssqlConnector
+	watermarkType string // declared type of a WatermarkTime column, read on the first poll
+}
+
+// NewChangePoller : poller on a watermark column, e.g. NewChangePoller("dbo.orders", "id", store)
+func (conn *MssqlConnector) NewChangePoller(table, watermarkColumn string, store WatermarkStore) *ChangePoller {
+	return &ChangePoller{
+		Table:           table,
+		WatermarkColumn: watermarkColumn,
+		Interval:        5 * time.Second,
+		BatchSize:       1000,
+		Store:           store,
+		StoreKey:        table + "." + watermarkColumn,
+		conn:            conn,
+	}
+}
+
+// NewChangeTrackingPoller : poller using SQL Server Change Tracking, keyColumns is the primary key of table
+func (conn *MssqlConnector) NewChangeTrackingPoller(table string, keyColumns []string, store WatermarkStore) *ChangePoller {
+	return &ChangePoller{
+		Table:          table,
+		KeyColumns:     keyColumns,
+		ChangeTracking: true,
+		Interval:       5 * time.Second,
+		Store:          store,
+		StoreKey:       table + ".ct",
+		conn:           conn,
+	}
+}
+
+// Run : poll every Interval until ctx is done or handler returns an error
+// database errors are logged and retried at the next poll
+func (cp *ChangePoller) Run(ctx context.Context, handler func(batch *DataReader) error) error {
+	interval := cp.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := cp.Poll(handler)
+		var he *handlerError
+		if errors.As(err, &he) {
+			return he.err
+		}
+		if err != nil {
+			cp.conn.logger().Log(LevelError, "change poll failed", "table", cp.Table, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string { return e.err.Error() }
+func (e *handlerError) Unwrap() error { return e.err }
+
+// Poll : read all pending changes now and pass them to handler batch by batch, return number of rows
+func (cp *ChangePoller) Poll(handler func(batch *DataReader) error) (int, error) {
+	if cp.Store == nil {
+		return 0, errors.New("change poll: no watermark store")
+	}
+	if cp.ChangeTracking {
+		return cp.pollChangeTracking(handler)
+	}
+	return cp.pollWatermark(handler)
+}
+
+func (cp *ChangePoller) pollWatermark(handler func(batch *DataReader) error) (int, error) {
+	if cp.WatermarkColumn == "" {
+		return 0, errors.New("change poll: no watermark column")
+	}
+	d := DialectMssql
+	size := cp.BatchSize
+	if size <= 0 {
+		size = 1000
+	}
+	column := d.QuoteIdent(cp.WatermarkColumn)
+	var expr, compare string
+	var conditions []string
+	switch cp.WatermarkKind {
+	case WatermarkRowVersion:
+		expr, compare = "CAST("+column+" AS BIGINT)", "CAST(CAST(@p1 AS BIGINT) AS BINARY(8))"
+		// a transaction still in flight commits with a lower rowversion than rows already read
+		conditions = append(conditions, column+" < MIN_ACTIVE_ROWVERSION()")
+	case WatermarkTime:
+		typeName, err := cp.timeType()
+		if err != nil {
+			return 0, err
+		}
+		expr, compare = "CONVERT(VARCHAR(33), "+column+", 126)", "CAST(@p1 AS "+typeName+")"
+	default:
+		expr, compare = "CAST("+column+" AS BIGINT)", "CAST(@p1 AS BIGINT)"
+	}
+
+	total := 0
+	for {
+		last, ok, err := cp.Store.Load(cp.StoreKey)
+		if err != nil {
+			return total, err
+		}
+		var args []interface{}
+		where := conditions
+		if ok {
+			where = append([]string{column + " > " + compare}, conditions...)
+			args = append(args, last)
+		}
+		q := fmt.Sprintf("SELECT TOP (%d) %s, %s AS %s FROM %s%s ORDER BY %s",
+			size, cp.columnList("", d), expr, watermarkAlias, d.QuoteIdent(cp.Table), whereClause(where), column)
+
+		table, err := cp.conn.queryTable(q, args)
+		if err != nil {
+			return total, err
+		}
+		if table.RowCount() == 0 {
+			return total, nil
+		}
+		full := table.RowCount() >= size
+		watermark := fmt.Sprint(table.Rows[table.RowCount()-1][len(table.Columns)-1])
+		if full {
+			// the rows after the batch may share the last watermark, so the batch stops before that group,
+			// or reads the whole group when the batch holds nothing else
+			first := table.RowCount() - 1
+			for first > 0 && fmt.Sprint(table.Rows[first-1][len(table.Columns)-1]) == watermark {
+				first--
+			}
+			if first > 0 {
+				table.Rows = table.Rows[:first]
+				watermark = fmt.Sprint(table.Rows[first-1][len(table.Columns)-1])
+			} else {
+				q = fmt.Sprintf("SELECT %s, %s AS %s FROM %s WHERE %s = %s",
+					cp.columnList("", d), expr, watermarkAlias, d.QuoteIdent(cp.Table), column, compare)
+				if table, err = cp.conn.queryTable(q, []interface{}{watermark}); err != nil {
+					return total, err
+				}
+			}
+		}
+		dropLastColumn(table)
+
+		if table.RowCount() > 0 {
+			if err := handler(table.Reader()); err != nil {
+				return total, &handlerError{err}
+			}
+		}
+		if err := cp.Store.Save(cp.StoreKey, watermark); err != nil {
+			return total, err
+		}
+		total += table.RowCount()
+		if !full {
+			return total, nil
+		}
+	}
+}
+
+// timeType : declared type of a WatermarkTime column, the saved watermark is cast back to it
+// so a datetime, rounded to 1/300 s, compares equal to its own text
+func (cp *ChangePoller) timeType() (string, error) {
+	if cp.watermarkType != "" {
+		return cp.watermarkType, nil
+	}
+	table, err := cp.conn.queryTable("SELECT TYPE_NAME(system_type_id), scale FROM sys.columns WHERE object_id = OBJECT_ID(@p1) AND name = @p2",
+		[]interface{}{cp.Table, cp.WatermarkColumn})
+	if err != nil {
+		return "", err
+	}
+	if table.RowCount() == 0 {
+		return "", fmt.Errorf("change poll: no column %s in %s", cp.WatermarkColumn, cp.Table)
+	}
+	typeName := strings.ToUpper(fmt.Sprint(table.Rows[0][0]))
+	switch typeName {
+	case "DATETIME", "SMALLDATETIME", "DATE":
+	case "DATETIME2", "DATETIMEOFFSET", "TIME":
+		typeName = fmt.Sprintf("%s(%v)", typeName, table.Rows[0][1])
+	default:
+		return "", fmt.Errorf("change poll: watermark column %s is %s, not a date or time", cp.WatermarkColumn, typeName)
+	}
+	cp.watermarkType = typeName
+	return typeName, nil
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func (cp *ChangePoller) pollChangeTracking(handler func(batch *DataReader) error) (int, error) {
+	if len(cp.KeyColumns) == 0 {
+		return 0, errors.New("change poll: change tracking needs key columns")
+	}
+	d := DialectMssql
+
+	current, err := cp.scalarInt("SELECT CHANGE_TRACKING_CURRENT_VERSION()")
+	if err != nil {
+		return 0, err
+	}
+	saved, ok, err := cp.Store.Load(cp.StoreKey)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		// first run: start from now
+		return 0, cp.Store.Save(cp.StoreKey, strconv.FormatInt(current, 10))
+	}
+	last, err := strconv.ParseInt(saved, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("change poll: invalid saved version %q", saved)
+	}
+	minValid, err := cp.scalarInt("SELECT CHANGE_TRACKING_MIN_VALID_VERSION(OBJECT_ID(@p1))", cp.Table)
+	if err != nil {
+		return 0, err
+	}
+	if last < minValid {
+		return 0, ErrChangeTrackingExpired
+	}
+	if last >= current {
+		return 0, nil
+	}
+
+	keys := make([]string, len(cp.KeyColumns))
+	on := make([]string, len(cp.KeyColumns))
+	for i, k := range cp.KeyColumns {
+		keys[i] = "ct." + d.QuoteIdent(k)
+		on[i] = fmt.Sprintf("t.%s = ct.%s", d.QuoteIdent(k), d.QuoteIdent(k))
+	}
+	q := fmt.Sprintf(`SELECT ct.SYS_CHANGE_OPERATION AS change_operation, CAST(ct.SYS_CHANGE_VERSION AS BIGINT) AS change_version, %s, %s
+		FROM CHANGETABLE(CHANGES %s, @p1) AS ct
+		LEFT JOIN %s AS t ON %s
+		WHERE ct.SYS_CHANGE_VERSION <= @p2
+		ORDER BY ct.SYS_CHANGE_VERSION`,
+		strings.Join(keys, ", "), cp.columnList("t.", d), d.QuoteIdent(cp.Table), d.QuoteIdent(cp.Table), strings.Join(on, " AND "))
+
+	table, err := cp.conn.queryTable(q, []interface{}{last, current})
+	if err != nil {
+		return 0, err
+	}
+	if table.RowCount() > 0 {
+		if err := handler(table.Reader()); err != nil {
+			return 0, &handlerError{err}
+		}
+	}
+	return table.RowCount(), cp.Store.Save(cp.StoreKey, strconv.FormatInt(current, 10))
+}
+
+func (cp *ChangePoller) columnList(prefix string, d Dialect) string {
+	if len(cp.Columns) == 0 {
+		return prefix + "*"
+	}
+	output := make([]string, len(cp.Columns))
+	for i, c := range cp.Columns {
+		output[i] = prefix + d.QuoteIdent(c)
+	}
+	return strings.Join(output, ", ")
+}
+
+func (cp *ChangePoller) scalarInt(QueryString string, args ...interface{}) (int64, error) {
+	table, err := cp.conn.queryTable(QueryString, args)
+	if err != nil {
+		return 0, err
+	}
+	if table.RowCount() == 0 || table.Rows[0][0] == nil {
+		return 0, fmt.Errorf("change poll: %q returned null, is change tracking enabled?", QueryString)
+	}
+	return strconv.ParseInt(fmt.Sprint(table.Rows[0][0]), 10, 64)
+}
+
+func dropLastColumn(dt *DataTable) {
+	n := len(dt.Columns) - 1
+	dt.Columns, dt.TypeNames, dt.TypeNames2 = dt.Columns[:n], dt.TypeNames[:n], dt.TypeNames2[:n]
+	for i, row := range dt.Rows {
+		dt.Rows[i] = row[:n]
+	}
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+// WatermarkStore : keeps the last processed watermark of each poller
+type WatermarkStore interface {
+	Load(key string) (value string, ok bool, err error)
+	Save(key, value string) error
+}
+
+// MemoryWatermarkStore : in-memory store, watermarks are lost on restart
+type MemoryWatermarkStore struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func NewMemoryWatermarkStore() *MemoryWatermarkStore {
+	return &MemoryWatermarkStore{values: map[string]string{}}
+}
+
+func (s *MemoryWatermarkStore) Load(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	return v, ok, nil
+}
+
+func (s *MemoryWatermarkStore) Save(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+// FileWatermarkStore : watermarks saved as a JSON object in a file, replaced atomically on every save
+type FileWatermarkStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func NewFileWatermarkStore(path string) *FileWatermarkStore {
+	return &FileWatermarkStore{Path: path}
+}
+
+func (s *FileWatermarkStore) Load(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.read()
+	if err != nil {
+		return "", false, err
+	}
+	v, ok := values[key]
+	return v, ok, nil
+}
+
+func (s *FileWatermarkStore) Save(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.read()
+	if err != nil {
+		return err
+	}
+	values[key] = value
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+func (s *FileWatermarkStore) read() (map[string]string, error) {
+	values := map[string]string{}
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("watermark file %s: %w", s.Path, err)
+	}
+	return values, nil
+}
@@ -0,0 +1,272 @@
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/churikawit/gosrc/sql"
+	"github.com/churikawit/gosrc/sql/sqltest"
+)
+
+func TestFileWatermarkStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watermark.json")
+	store := sql.NewFileWatermarkStore(path)
+
+	if _, ok, err := store.Load("dbo.orders.id"); ok || err != nil {
+		t.Fatalf("Load on missing file == %v, %v", ok, err)
+	}
+	if err := store.Save("dbo.orders.id", "42"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("dbo.items.ct", "7"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := sql.NewFileWatermarkStore(path)
+	for key, want := range map[string]string{"dbo.orders.id": "42", "dbo.items.ct": "7"} {
+		got, ok, err := reopened.Load(key)
+		if err != nil || !ok || got != want {
+			t.Errorf("Load(%q) == %q, %v, %v, want %q", key, got, ok, err, want)
+		}
+	}
+}
+
+// orderRows : id, updated_at and the watermark column of a watermark query, pairs of id and time
+func orderRows(pairs ...interface{}) *sqltest.Rows {
+	rows := sqltest.NewRows(
+		sqltest.Column{Name: "id", TypeName: "INT"},
+		sqltest.Column{Name: "updated_at", TypeName: "VARCHAR"},
+		sqltest.Column{Name: "__watermark", TypeName: "VARCHAR"})
+	for i := 0; i < len(pairs); i += 2 {
+		rows.AddRow(pairs[i], pairs[i+1], pairs[i+1])
+	}
+	return rows
+}
+
+// expectColumnType : answer the lookup of the declared type of a WatermarkTime column
+func expectColumnType(fake *sqltest.DB, table, column, typeName string, scale int) {
+	fake.ExpectQuery("SELECT TYPE_NAME(system_type_id), scale FROM sys.columns WHERE object_id = OBJECT_ID(@p1) AND name = @p2").
+		WithArgs(table, column).
+		WillReturnRows(sqltest.NewRows(
+			sqltest.Column{Name: "", TypeName: "NVARCHAR"},
+			sqltest.Column{Name: "scale", TypeName: "TINYINT"}).AddRow(typeName, scale))
+}
+
+// collectIDs : handler that appends the first column of every batch
+func collectIDs(batches *[][]interface{}) func(batch *sql.DataReader) error {
+	return func(batch *sql.DataReader) error {
+		var ids []interface{}
+		for batch.Read() {
+			ids = append(ids, batch.GetValue2(0))
+		}
+		*batches = append(*batches, ids)
+		return nil
+	}
+}
+
+func TestPollWatermarkTimeTies(t *testing.T) {
+	conn, fake := openMssql(t)
+	const (
+		t0 = "2021-06-01T00:00:00"
+		t1 = "2021-06-01T08:00:00"
+		t2 = "2021-06-01T09:00:00"
+		t3 = "2021-06-01T10:00:00"
+	)
+	expectColumnType(fake, "dbo.orders", "updated_at", "datetime2", 7)
+	next := "SELECT TOP (3) [id], [updated_at], CONVERT(VARCHAR(33), [updated_at], 126) AS __watermark FROM [dbo].[orders] " +
+		"WHERE [updated_at] > CAST(@p1 AS DATETIME2(7)) ORDER BY [updated_at]"
+	group := "SELECT [id], [updated_at], CONVERT(VARCHAR(33), [updated_at], 126) AS __watermark FROM [dbo].[orders] " +
+		"WHERE [updated_at] = CAST(@p1 AS DATETIME2(7))"
+	// a full batch ending inside the t2 group stops before it
+	fake.ExpectQuery(next).WithArgs(t0).WillReturnRows(orderRows(1, t1, 2, t2, 3, t2))
+	// a full batch of only t2 rows reads the whole group
+	fake.ExpectQuery(next).WithArgs(t1).WillReturnRows(orderRows(2, t2, 3, t2, 4, t2))
+	fake.ExpectQuery(group).WithArgs(t2).WillReturnRows(orderRows(2, t2, 3, t2, 4, t2, 5, t2))
+	fake.ExpectQuery(next).WithArgs(t2).WillReturnRows(orderRows(6, t3))
+
+	store := sql.NewMemoryWatermarkStore()
+	store.Save("dbo.orders.updated_at", t0)
+	cp := conn.NewChangePoller("dbo.orders", "updated_at", store)
+	cp.Columns = []string{"id", "updated_at"}
+	cp.WatermarkKind = sql.WatermarkTime
+	cp.BatchSize = 3
+
+	var batches [][]interface{}
+	n, err := cp.Poll(collectIDs(&batches))
+	if err != nil || n != 6 {
+		t.Fatalf("Poll() == %d, %v, want 6, nil", n, err)
+	}
+	want := [][]interface{}{{int32(1)}, {int32(2), int32(3), int32(4), int32(5)}, {int32(6)}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("batches == %v, want %v", batches, want)
+	}
+	if got, _, _ := store.Load("dbo.orders.updated_at"); got != t3 {
+		t.Errorf("watermark == %q, want %q", got, t3)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPollWatermarkDatetime(t *testing.T) {
+	conn, fake := openMssql(t)
+	// datetime keeps 1/300 s, its text is only equal to the stored value when cast back to datetime
+	const (
+		t1 = "2021-06-01T10:00:00.003"
+		t2 = "2021-06-01T10:00:00.007"
+	)
+	expectColumnType(fake, "dbo.orders", "updated_at", "datetime", 3)
+	first := "SELECT TOP (2) [id], [updated_at], CONVERT(VARCHAR(33), [updated_at], 126) AS __watermark FROM [dbo].[orders] " +
+		"ORDER BY [updated_at]"
+	next := "SELECT TOP (2) [id], [updated_at], CONVERT(VARCHAR(33), [updated_at], 126) AS __watermark FROM [dbo].[orders] " +
+		"WHERE [updated_at] > CAST(@p1 AS DATETIME) ORDER BY [updated_at]"
+	group := "SELECT [id], [updated_at], CONVERT(VARCHAR(33), [updated_at], 126) AS __watermark FROM [dbo].[orders] " +
+		"WHERE [updated_at] = CAST(@p1 AS DATETIME)"
+	// three rows share t1, the boundary of the first batch
+	fake.ExpectQuery(first).WillReturnRows(orderRows(1, t1, 2, t1))
+	fake.ExpectQuery(group).WithArgs(t1).WillReturnRows(orderRows(1, t1, 2, t1, 3, t1))
+	fake.ExpectQuery(next).WithArgs(t1).WillReturnRows(orderRows(4, t2))
+	fake.ExpectQuery(next).WithArgs(t2).WillReturnRows(orderRows())
+
+	store := sql.NewMemoryWatermarkStore()
+	cp := conn.NewChangePoller("dbo.orders", "updated_at", store)
+	cp.Columns = []string{"id", "updated_at"}
+	cp.WatermarkKind = sql.WatermarkTime
+	cp.BatchSize = 2
+
+	var batches [][]interface{}
+	if n, err := cp.Poll(collectIDs(&batches)); err != nil || n != 4 {
+		t.Fatalf("first Poll() == %d, %v, want 4, nil", n, err)
+	}
+	if n, err := cp.Poll(collectIDs(&batches)); err != nil || n != 0 {
+		t.Fatalf("second Poll() == %d, %v, want 0, nil", n, err)
+	}
+	want := [][]interface{}{{int32(1), int32(2), int32(3)}, {int32(4)}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("batches == %v, want %v", batches, want)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPollWatermarkRowVersion(t *testing.T) {
+	conn, fake := openMssql(t)
+	fake.ExpectQuery("SELECT TOP (1000) *, CAST([rv] AS BIGINT) AS __watermark FROM [dbo].[orders] " +
+		"WHERE [rv] < MIN_ACTIVE_ROWVERSION() ORDER BY [rv]").
+		WillReturnRows(orderRows(1, "2001", 2, "2002"))
+	fake.ExpectQuery("SELECT TOP (1000) *, CAST([rv] AS BIGINT) AS __watermark FROM [dbo].[orders] " +
+		"WHERE [rv] > CAST(CAST(@p1 AS BIGINT) AS BINARY(8)) AND [rv] < MIN_ACTIVE_ROWVERSION() ORDER BY [rv]").
+		WithArgs("2002").
+		WillReturnRows(orderRows())
+
+	store := sql.NewMemoryWatermarkStore()
+	cp := conn.NewChangePoller("dbo.orders", "rv", store)
+	cp.WatermarkKind = sql.WatermarkRowVersion
+
+	var batches [][]interface{}
+	if n, err := cp.Poll(collectIDs(&batches)); err != nil || n != 2 {
+		t.Fatalf("first Poll() == %d, %v, want 2, nil", n, err)
+	}
+	if n, err := cp.Poll(collectIDs(&batches)); err != nil || n != 0 {
+		t.Fatalf("second Poll() == %d, %v, want 0, nil", n, err)
+	}
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Errorf("batches == %v, want one batch of 2 rows", batches)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPollChangeTracking(t *testing.T) {
+	conn, fake := openMssql(t)
+	version := func(v int64) *sqltest.Rows {
+		return sqltest.NewRows(sqltest.Column{Name: "", TypeName: "BIGINT"}).AddRow(v)
+	}
+	fake.ExpectQuery("SELECT CHANGE_TRACKING_CURRENT_VERSION()").WillReturnRows(version(10))
+	fake.ExpectQuery("SELECT CHANGE_TRACKING_CURRENT_VERSION()").WillReturnRows(version(12))
+	fake.ExpectQuery("SELECT CHANGE_TRACKING_MIN_VALID_VERSION(OBJECT_ID(@p1))").WithArgs("dbo.orders").WillReturnRows(version(5))
+	fake.ExpectQueryRegexp(`^SELECT ct\.SYS_CHANGE_OPERATION AS change_operation, .*, ct\.\[id\], t\.\*\s+`+
+		`FROM CHANGETABLE\(CHANGES \[dbo\]\.\[orders\], @p1\) AS ct\s+LEFT JOIN \[dbo\]\.\[orders\] AS t ON t\.\[id\] = ct\.\[id\]\s+`+
+		`WHERE ct\.SYS_CHANGE_VERSION <= @p2\s+ORDER BY ct\.SYS_CHANGE_VERSION$`).
+		WithArgs(int64(10), int64(12)).
+		WillReturnRows(sqltest.NewRows(
+			sqltest.Column{Name: "change_operation", TypeName: "NCHAR"},
+			sqltest.Column{Name: "change_version", TypeName: "BIGINT"},
+			sqltest.Column{Name: "id", TypeName: "INT"}).
+			AddRow("U", int64(11), 1).
+			AddRow("D", int64(12), 2))
+	fake.ExpectQuery("SELECT CHANGE_TRACKING_CURRENT_VERSION()").WillReturnRows(version(20))
+	fake.ExpectQuery("SELECT CHANGE_TRACKING_MIN_VALID_VERSION(OBJECT_ID(@p1))").WithArgs("dbo.orders").WillReturnRows(version(15))
+
+	store := sql.NewMemoryWatermarkStore()
+	cp := conn.NewChangeTrackingPoller("dbo.orders", []string{"id"}, store)
+	var batches [][]interface{}
+	handler := func(batch *sql.DataReader) error {
+		for batch.Read() {
+			batches = append(batches, []interface{}{batch.GetValue2(0), batch.GetValue2(2)})
+		}
+		return nil
+	}
+
+	if n, err := cp.Poll(handler); err != nil || n != 0 {
+		t.Fatalf("first Poll() == %d, %v, want 0, nil", n, err)
+	}
+	if n, err := cp.Poll(handler); err != nil || n != 2 {
+		t.Fatalf("second Poll() == %d, %v, want 2, nil", n, err)
+	}
+	if want := [][]interface{}{{"U", int32(1)}, {"D", int32(2)}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("changes == %v, want %v", batches, want)
+	}
+	if got, _, _ := store.Load("dbo.orders.ct"); got != "12" {
+		t.Errorf("saved version == %q, want 12", got)
+	}
+	if _, err := cp.Poll(handler); err != sql.ErrChangeTrackingExpired {
+		t.Errorf("Poll() after retention == %v, want ErrChangeTrackingExpired", err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChangePollerRun(t *testing.T) {
+	conn, fake := openMssql(t)
+	var logged []string
+	conn.SetLogger(sql.LoggerFunc(func(level sql.Level, msg string, keyvals ...interface{}) {
+		logged = append(logged, fmt.Sprint(level, " ", msg))
+	}))
+	q := "SELECT TOP (1000) *, CAST([id] AS BIGINT) AS __watermark FROM [dbo].[orders] ORDER BY [id]"
+	fake.ExpectQuery(q).WillReturnError(errors.New("connection reset"))
+	fake.ExpectQuery(q).WillReturnRows(orderRows(1, "1"))
+
+	cp := conn.NewChangePoller("dbo.orders", "id", sql.NewMemoryWatermarkStore())
+	cp.Interval = time.Millisecond
+	errStop := errors.New("stop")
+	polls := 0
+	err := cp.Run(context.Background(), func(batch *sql.DataReader) error {
+		polls++
+		return errStop
+	})
+	if err != errStop || polls != 1 {
+		t.Errorf("Run() == %v after %d batches, want the handler error after 1", err, polls)
+	}
+	if len(logged) == 0 || logged[len(logged)-1] != "ERROR change poll failed" {
+		t.Errorf("logged == %q, want the failed poll", logged)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fake.ExpectQuery(q).WillReturnRows(orderRows())
+	if err := cp.Run(ctx, collectIDs(new([][]interface{}))); err != context.Canceled {
+		t.Errorf("Run() with a cancelled context == %v, want context.Canceled", err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
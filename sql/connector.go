@@ -0,0 +1,215 @@
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrNotOpen : returned when a connector is used before OpenConnection
+var ErrNotOpen = errors.New("sql: connection is not open")
+
+// runner : *sql.DB or *sql.Tx
+type runner interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// executor : connector or transaction, lets helpers run the same code on either
+type executor interface {
+	query(QueryString string, args ...interface{}) (*DataReader, error)
+	exec(QueryString string, args ...interface{}) (sql.Result, error)
+	Dialect() Dialect
+}
+
+// baseConnector : state and query plumbing shared by MssqlConnector and PostgresConnector
+type baseConnector struct {
+	db      *sql.DB
+	dialect Dialect
+
+	// DriverName is the database/sql driver to open, empty means "sqlserver" or "postgres",
+	// tests set it to sqltest.DriverName
+	DriverName string
+
+	// Logger receives connection and query messages, nil means the package default logger
+	Logger Logger
+	// LogQueries logs every statement with its arguments, duration and number of rows
+	LogQueries bool
+	// SlowQueryThreshold logs a warning for statements running longer than this, 0 disables it
+	SlowQueryThreshold time.Duration
+
+	hooks []QueryHook
+}
+
+// SetLogger : replace the logger of this connector, nil falls back to the package default logger
+func (bc *baseConnector) SetLogger(l Logger) {
+	bc.Logger = l
+}
+
+// AddHook : register a hook called around every Query and NonQuery
+func (bc *baseConnector) AddHook(h QueryHook) {
+	bc.hooks = append(bc.hooks, h)
+}
+
+func (bc *baseConnector) logger() Logger {
+	if bc.Logger != nil {
+		return bc.Logger
+	}
+	return defaultLogger
+}
+
+func (bc *baseConnector) driverName(defaultName string) string {
+	if bc.DriverName != "" {
+		return bc.DriverName
+	}
+	return defaultName
+}
+
+// DB : underlying database/sql handle, nil before OpenConnection
+func (bc *baseConnector) DB() *sql.DB {
+	return bc.db
+}
+
+func (bc *baseConnector) CloseConnection() {
+	if bc.db != nil {
+		bc.db.Close()
+	}
+}
+
+// Query : run a statement returning rows, return nil if error
+func (bc *baseConnector) Query(QueryString string, args ...interface{}) *DataReader {
+	reader, err := bc.query(QueryString, args...)
+	if err != nil {
+		bc.logger().Log(LevelError, "query failed", "error", err)
+		return nil
+	}
+	return reader
+}
+
+// NonQuery : run a statement without reading any rows
+func (bc *baseConnector) NonQuery(QueryString string, args ...interface{}) error {
+	_, err := bc.exec(QueryString, args...)
+	if err != nil {
+		bc.logger().Log(LevelError, "non-query failed", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (bc *baseConnector) query(QueryString string, args ...interface{}) (*DataReader, error) {
+	if bc.db == nil {
+		return nil, ErrNotOpen
+	}
+	return bc.queryOn(bc.db, QueryString, args...)
+}
+
+// queryConn : Query on any DbConnector keeping the error, connectors of this package report it, others only give nil
+func queryConn(conn DbConnector, QueryString string, args ...interface{}) (*DataReader, error) {
+	if q, ok := conn.(interface {
+		query(QueryString string, args ...interface{}) (*DataReader, error)
+	}); ok {
+		return q.query(QueryString, args...)
+	}
+	if reader := conn.Query(QueryString, args...); reader != nil {
+		return reader, nil
+	}
+	return nil, errQueryFailed
+}
+
+var errQueryFailed = errors.New("sql: query failed")
+
+func (bc *baseConnector) exec(QueryString string, args ...interface{}) (sql.Result, error) {
+	if bc.db == nil {
+		return nil, ErrNotOpen
+	}
+	return bc.execOn(bc.db, QueryString, args...)
+}
+
+// queryOn : instrumented Query on a database or transaction
+// hooks see the query when it returns and again when its rows are read, see RowsHook,
+// errors met later while reading are logged and kept in reader.Err
+func (bc *baseConnector) queryOn(r runner, QueryString string, args ...interface{}) (*DataReader, error) {
+	e := bc.beginQuery(QueryString, args)
+	rows, err := r.Query(QueryString, args...)
+	if err != nil {
+		e.Err = err
+		bc.endQuery(e)
+		return nil, err
+	}
+	reader := CreateDataReader(rows)
+	if err := reader.err; err != nil {
+		rows.Close()
+		e.Err = err
+		bc.endQuery(e)
+		return nil, err
+	}
+	e.HasRows = true
+	bc.endQuery(e)
+	reader.onError = func(err error) {
+		bc.logger().Log(LevelError, "read failed", "label", e.Label, "error", err)
+	}
+	reader.onDone = func() {
+		done := *e
+		done.Rows, done.Err = reader.rowCount, reader.err
+		bc.endRows(&done)
+	}
+	return reader, nil
+}
+
+// execOn : instrumented Exec on a database or transaction
+func (bc *baseConnector) execOn(r runner, QueryString string, args ...interface{}) (sql.Result, error) {
+	e := bc.beginQuery(QueryString, args)
+	result, err := r.Exec(QueryString, args...)
+	if err == nil {
+		e.Rows, _ = result.RowsAffected()
+	}
+	e.Err = err
+	bc.endQuery(e)
+	return result, err
+}
+
+func (bc *baseConnector) beginQuery(QueryString string, args []interface{}) *QueryEvent {
+	e := &QueryEvent{Label: QueryLabel(QueryString), SQL: QueryString, Args: args}
+	for _, h := range bc.hooks {
+		h.BeforeQuery(e)
+	}
+	e.Start = time.Now()
+	return e
+}
+
+// endQuery : run after hooks, structured query log and slow query log,
+// the logs of a Query that returned rows wait for endRows
+func (bc *baseConnector) endQuery(e *QueryEvent) {
+	e.Duration = time.Since(e.Start)
+	for _, h := range bc.hooks {
+		h.AfterQuery(e)
+	}
+	if !e.HasRows {
+		bc.logQuery(e)
+	}
+}
+
+// endRows : rows of a Query read, duration runs from the statement to the last row
+func (bc *baseConnector) endRows(e *QueryEvent) {
+	e.Duration = time.Since(e.Start)
+	for _, h := range bc.hooks {
+		if rh, ok := h.(RowsHook); ok {
+			rh.AfterRows(e)
+		}
+	}
+	bc.logQuery(e)
+}
+
+func (bc *baseConnector) logQuery(e *QueryEvent) {
+	if bc.LogQueries {
+		if e.Err != nil {
+			bc.logger().Log(LevelInfo, "query", "sql", e.SQL, "args", e.Args, "duration", e.Duration, "error", e.Err)
+		} else {
+			bc.logger().Log(LevelInfo, "query", "sql", e.SQL, "args", e.Args, "duration", e.Duration, "rows", e.Rows)
+		}
+	}
+	if bc.SlowQueryThreshold > 0 && e.Duration >= bc.SlowQueryThreshold {
+		bc.logger().Log(LevelWarn, "slow query", "label", e.Label, "sql", e.SQL, "duration", e.Duration,
+			"threshold", bc.SlowQueryThreshold, "rows", e.Rows)
+	}
+}
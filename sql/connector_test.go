@@ -0,0 +1,228 @@
+package sql_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/churikawit/gosrc/sql"
+	"github.com/churikawit/gosrc/sql/sqltest"
+)
+
+func openPostgres(t *testing.T) (*sql.PostgresConnector, *sqltest.DB) {
+	fake := sqltest.New()
+	conn := sql.NewPostgresConnector2(fake.DSN())
+	conn.DriverName = sqltest.DriverName
+	conn.SetLogger(sql.NopLogger())
+	if err := conn.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.CloseConnection)
+	return conn, fake
+}
+
+func openMssql(t *testing.T) (*sql.MssqlConnector, *sqltest.DB) {
+	fake := sqltest.New()
+	fake.ExpectQuery("SELECT @@version").
+		WillReturnRows(sqltest.NewRows(sqltest.Column{Name: "", TypeName: "NVARCHAR"}).AddRow("Microsoft SQL Server 2017")).
+		AnyTimes()
+	conn := sql.NewMssqlConnector2(fake.DSN())
+	conn.DriverName = sqltest.DriverName
+	conn.SetLogger(sql.NopLogger())
+	if err := conn.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.CloseConnection)
+	return conn, fake
+}
+
+func TestQueryDataReader(t *testing.T) {
+	conn, fake := openPostgres(t)
+	created := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	fake.ExpectQuery("select * from sales where branch=$1").
+		WithArgs("BKK").
+		WillReturnRows(sqltest.NewRows(
+			sqltest.Column{Name: "doc_no", TypeName: "VARCHAR", Length: 20},
+			sqltest.Column{Name: "amount", TypeName: "DECIMAL", Precision: 10, Scale: 2},
+			sqltest.Column{Name: "qty", TypeName: "INT"},
+			sqltest.Column{Name: "paid", TypeName: "BOOL"},
+			sqltest.Column{Name: "doc_date", TypeName: "DATE"},
+		).
+			AddRow("S001", 10.5, 3, true, created).
+			AddRow("S002", nil, 1, false, nil))
+
+	var events, rowEvents []*sql.QueryEvent
+	conn.AddHook(sql.HookFuncs{
+		After: func(e *sql.QueryEvent) { events = append(events, e) },
+		Rows:  func(e *sql.QueryEvent) { rowEvents = append(rowEvents, e) },
+	})
+
+	reader := conn.Query("select * from sales\n where branch=$1", "BKK")
+	if reader == nil {
+		t.Fatal("Query returned nil")
+	}
+	if len(events) != 1 || events[0].Err != nil || !events[0].HasRows || len(rowEvents) != 0 {
+		t.Errorf("hook events before reading == %+v, %+v", events, rowEvents)
+	}
+	if got := reader.GetDataTypeName2(0) + " " + reader.GetDataTypeName2(1); got != "VARCHAR(20) DECIMAL(10,2)" {
+		t.Errorf("GetDataTypeName2 == %q", got)
+	}
+	var got [][]interface{}
+	for reader.Read() {
+		got = append(got, reader.GetValues())
+	}
+	reader.Close()
+
+	want := [][]interface{}{
+		{"S001", 10.5, int32(3), true, created},
+		{"S002", nil, int32(1), false, nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows == %v, want %v", got, want)
+	}
+	if len(events) != 1 || reader.Err() != nil {
+		t.Errorf("after hook events == %+v, Err() == %v", events, reader.Err())
+	}
+	if len(rowEvents) != 1 || rowEvents[0].Rows != 2 || rowEvents[0].Duration < events[0].Duration {
+		t.Errorf("rows hook events == %+v, want one with 2 rows", rowEvents)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQueryScanError(t *testing.T) {
+	conn, fake := openPostgres(t)
+	fake.ExpectQuery("select qty from sales").
+		WillReturnRows(sqltest.NewRows(sqltest.Column{Name: "qty", TypeName: "INT"}).
+			AddRow(1).
+			AddRow("many").
+			AddRow(3))
+
+	reader := conn.Query("select qty from sales")
+	if reader == nil {
+		t.Fatal("Query returned nil")
+	}
+	n := 0
+	for reader.Read() {
+		n++
+	}
+	if n != 1 || reader.Err() == nil {
+		t.Errorf("read %d rows, Err() == %v, want 1 row and a scan error", n, reader.Err())
+	}
+	if reader.Read() {
+		t.Error("Read after a scan error == true, want false")
+	}
+	reader.Close()
+}
+
+func TestSlowQueryLogRows(t *testing.T) {
+	conn, fake := openPostgres(t)
+	var logged [][]interface{}
+	conn.SetLogger(sql.LoggerFunc(func(level sql.Level, msg string, keyvals ...interface{}) {
+		if msg == "slow query" {
+			logged = append(logged, keyvals)
+		}
+	}))
+	conn.SlowQueryThreshold = time.Nanosecond
+	fake.ExpectQuery("select qty from sales").
+		WillReturnRows(sqltest.NewRows(sqltest.Column{Name: "qty", TypeName: "INT"}).AddRow(1).AddRow(2))
+
+	reader := conn.Query("select qty from sales")
+	if reader == nil {
+		t.Fatal("Query returned nil")
+	}
+	if len(logged) != 0 {
+		t.Errorf("slow query logged before reading: %v", logged)
+	}
+	reader.Read()
+	reader.Close()
+	if len(logged) != 1 || fmt.Sprint(logged[0][len(logged[0])-2:]) != "[rows 1]" {
+		t.Errorf("slow query log == %v, want one with the 1 row read", logged)
+	}
+}
+
+func TestUpsertPostgres(t *testing.T) {
+	conn, fake := openPostgres(t)
+	fake.ExpectQueryRegexp(`^INSERT INTO "register_user" .* ON CONFLICT \("user_id", "bot_id"\)`).
+		WithArgs(1, "Somchai", "U1").
+		WillReturnRows(sqltest.NewRows(sqltest.Column{Name: "inserted", TypeName: "BOOL"}).AddRow(false))
+
+	inserted, err := conn.Upsert("register_user", []string{"user_id", "bot_id"},
+		map[string]interface{}{"user_id": "U1", "bot_id": 1, "display_name": "Somchai"})
+	if err != nil || inserted {
+		t.Errorf("Upsert == %v, %v, want false, nil", inserted, err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatchInsertMssql(t *testing.T) {
+	conn, fake := openMssql(t)
+	fake.ExpectExecRegexp(`^INSERT INTO \[log_event\] \(\[bot_id\], \[event_type\]\) VALUES \(@p1, @p2\), \(@p3, @p4\)$`).
+		WillReturnResult(0, 2).Times(2)
+	fake.ExpectExec(`INSERT INTO [log_event] ([bot_id], [event_type]) VALUES (@p1, @p2)`).
+		WithArgs(5, "follow").
+		WillReturnResult(0, 1)
+
+	rows := [][]interface{}{{1, "message"}, {2, "message"}, {3, "follow"}, {4, "message"}, {5, "follow"}}
+	var progress []int64
+	count, err := conn.BatchInsert("log_event", []string{"bot_id", "event_type"}, rows, sql.BatchInsertOptions{
+		BatchSize: 2,
+		Progress:  func(batch int, rows int64) { progress = append(progress, rows) },
+	})
+	if err != nil || count != 5 {
+		t.Fatalf("BatchInsert == %d, %v", count, err)
+	}
+	if !reflect.DeepEqual(progress, []int64{2, 4, 5}) {
+		t.Errorf("progress == %v", progress)
+	}
+	calls := fake.Calls()
+	if calls[1].Kind != "begin" || calls[len(calls)-1].Kind != "commit" {
+		t.Errorf("BatchInsert must run in a transaction, calls == %+v", calls)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunScriptError(t *testing.T) {
+	conn, fake := openMssql(t)
+	fake.ExpectExec("create table a(id int)")
+	fake.ExpectExec("insert into a values('x')").WillReturnError(errors.New("conversion failed"))
+
+	script := "create table a(id int)\nGO\n\ninsert into a values('x')\nGO\nselect 1\n"
+	_, err := conn.RunScript(script, sql.ScriptOptions{InTransaction: true})
+	var se *sql.ScriptError
+	if !errors.As(err, &se) || se.Batch != 2 || se.StartLine != 4 {
+		t.Fatalf("RunScript error == %#v", err)
+	}
+	calls := fake.Calls()
+	if calls[len(calls)-1].Kind != "rollback" {
+		t.Errorf("RunScript must roll back, calls == %+v", calls)
+	}
+}
+
+func TestQueryPage(t *testing.T) {
+	conn, fake := openMssql(t)
+	fake.ExpectQuery("SELECT COUNT(*) FROM (select * from log_event where bot_id=@p1) AS page_src").
+		WithArgs(1).
+		WillReturnRows(sqltest.NewRows(sqltest.Column{TypeName: "INT"}).AddRow(45))
+	fake.ExpectQuery("SELECT * FROM (select * from log_event where bot_id=@p1) AS page_src ORDER BY id OFFSET 20 ROWS FETCH NEXT 20 ROWS ONLY").
+		WithArgs(1).
+		WillReturnRows(sqltest.NewRows(sqltest.Column{Name: "id", TypeName: "BIGINT"}).AddRow(21).AddRow(22))
+
+	page, err := conn.QueryPage("select * from log_event where bot_id=@p1", "id", 2, 20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.TotalCount != 45 || page.PageCount() != 3 || !page.HasNext || page.RowCount() != 2 {
+		t.Errorf("page == %+v", page)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
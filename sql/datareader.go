@@ -2,8 +2,6 @@ package sql
 
 import (
 	"database/sql"
-	"fmt"
-	"log"
 	"reflect"
 
 	"golang.org/x/text/encoding/charmap"
@@ -12,9 +10,19 @@ import (
 // DataReader : Object
 type DataReader struct {
 	rows        *sql.Rows
-	columnType  []*sql.ColumnType
+	table       *DataTable // set instead of rows when replaying a DataTable
+	pos         int
+	names       []string
+	typeNames   []string
+	typeNames2  []string
 	vals        []interface{}
 	columnCount int
+
+	rowCount int64
+	closed   bool
+	err      error
+	onError  func(err error) // connector logging of errors met while reading
+	onDone   func()          // connector hooks once the rows are read to the end, fail or are closed
 }
 
 // CreateDataReader : get a wrapper for sql.Rows
@@ -22,73 +30,117 @@ func CreateDataReader(rows *sql.Rows) *DataReader {
 	reader := new(DataReader)
 	reader.rows = rows
 
-	var err error
-	reader.columnType, err = rows.ColumnTypes()
+	columnType, err := rows.ColumnTypes()
 	if err != nil {
-		log.Fatal(err)
+		reader.err = err
+		return reader
 	}
 
-	reader.columnCount = len(reader.columnType)
-	reader.vals = make([]interface{}, len(reader.columnType))
-	for i, ct := range reader.columnType {
+	reader.columnCount = len(columnType)
+	reader.names = make([]string, len(columnType))
+	reader.typeNames = make([]string, len(columnType))
+	reader.typeNames2 = make([]string, len(columnType))
+	for i, ct := range columnType {
 		// fmt.Printf("%- 25s: %s\n", (*ct).Name(), (*ColumnType)(ct).DatabaseTypeName2())
-		switch (*ct).DatabaseTypeName() {
+		reader.names[i] = ct.Name()
+		reader.typeNames[i] = ct.DatabaseTypeName()
+		reader.typeNames2[i] = (*ColumnType)(ct).DatabaseTypeName2()
+	}
+	reader.vals = newScanValues(reader.typeNames)
+	return reader
+}
+
+// newScanValues : scan destination for each column by database type name
+func newScanValues(typeNames []string) []interface{} {
+	vals := make([]interface{}, len(typeNames))
+	for i, typeName := range typeNames {
+		switch typeName {
 		case "VARCHAR":
-			reader.vals[i] = new(sql.NullString)
+			vals[i] = new(sql.NullString)
 		case "CHAR":
-			reader.vals[i] = new(sql.NullString)
+			vals[i] = new(sql.NullString)
 		case "TEXT":
-			reader.vals[i] = new(sql.NullString)
+			vals[i] = new(sql.NullString)
 		case "NVARCHAR":
-			reader.vals[i] = new(sql.NullString)
+			vals[i] = new(sql.NullString)
 		case "DECIMAL":
-			reader.vals[i] = new(sql.NullFloat64)
+			vals[i] = new(sql.NullFloat64)
 		case "FLOAT":
-			reader.vals[i] = new(sql.NullFloat64)
+			vals[i] = new(sql.NullFloat64)
 		case "BOOL":
-			reader.vals[i] = new(sql.NullBool)
+			vals[i] = new(sql.NullBool)
 		case "INT":
-			reader.vals[i] = new(sql.NullInt32)
+			vals[i] = new(sql.NullInt32)
 		case "INT8":
-			reader.vals[i] = new(sql.NullInt32)
+			vals[i] = new(sql.NullInt32)
 		case "BIGINT":
-			reader.vals[i] = new(sql.NullInt64)
+			vals[i] = new(sql.NullInt64)
 		case "DATE":
-			reader.vals[i] = new(sql.NullTime)
+			vals[i] = new(sql.NullTime)
 		default:
-			reader.vals[i] = new(sql.NullString)
+			vals[i] = new(sql.NullString)
 		}
 	}
-	return reader
+	return vals
 }
 
 func (dr *DataReader) Read() bool {
+	if dr.table != nil {
+		return dr.readTable()
+	}
 
+	if dr.err != nil || dr.closed {
+		return false
+	}
 	output := dr.rows.Next()
 	if !output {
+		if err := dr.rows.Err(); err != nil {
+			dr.setErr(err)
+		}
+		dr.done()
 		return output
 	}
 	err := dr.rows.Scan(dr.vals...)
 	if err != nil {
-		log.Fatal(err)
+		dr.setErr(err)
+		dr.done()
+		return false
 	}
+	dr.rowCount++
 	return output
 }
 
+// setErr : keep the first error met while reading, Read returns false from then on
+func (dr *DataReader) setErr(err error) {
+	if dr.err != nil {
+		return
+	}
+	dr.err = err
+	if dr.onError != nil {
+		dr.onError(err)
+	}
+}
+
+// done : call onDone once
+func (dr *DataReader) done() {
+	if f := dr.onDone; f != nil {
+		dr.onDone = nil
+		f()
+	}
+}
+
 // GetName : return FieldName
 func (dr *DataReader) GetName(i int) string {
 	if i >= dr.FieldCount() {
 		return ""
 	}
-	return dr.columnType[i].Name()
+	return dr.names[i]
 }
 
 func (dr *DataReader) GetNames() []string {
 	c := dr.FieldCount()
 	output := make([]string, c)
-	for i := 0; i < c; i++ {
-		output[i] = dr.columnType[i].Name()
-	}
+	copy(output, dr.names)
 	return output
 }
 
@@ -97,7 +149,7 @@ func (dr *DataReader) GetDataTypeName(i int) string {
 	if i >= dr.FieldCount() {
 		return ""
 	}
-	return dr.columnType[i].DatabaseTypeName()
+	return dr.typeNames[i]
 }
 
 // GetDataTypeName2 : return {VARCHAR(5), DECIMAL(10,2), TEXT, BOOL, INT, BIGINT, DATE, etc...}
@@ -105,7 +157,7 @@ func (dr *DataReader) GetDataTypeName2(i int) string {
 	if i >= dr.FieldCount() {
 		return ""
 	}
-	return (*ColumnType)(dr.columnType[i]).DatabaseTypeName2()
+	return dr.typeNames2[i]
 }
 
 // GetFieldType : return Type of field
@@ -180,9 +232,29 @@ func (dr *DataReader) FieldCount() int {
 	return dr.columnCount
 }
 
-// Close : close reader
+// Err : return the error met while reading column types, scanning or iterating rows, if any
+func (dr *DataReader) Err() error {
+	if dr.err != nil || dr.rows == nil {
+		return dr.err
+	}
+	return dr.rows.Err()
+}
+
+// RowCount : return number of rows read so far
+func (dr *DataReader) RowCount() int64 {
+	return dr.rowCount
+}
+
+// Close : close reader, safe to call more than once
 func (dr *DataReader) Close() {
-	dr.rows.Close()
+	if dr == nil || dr.closed {
+		return
+	}
+	dr.closed = true
+	if dr.rows != nil {
+		dr.rows.Close()
+	}
+	dr.done()
 }
 
 // IsNull : return True if field is null value
@@ -246,13 +318,7 @@ type ColumnType sql.ColumnType
 func (v *ColumnType) DatabaseTypeName2() string {
 
 	ct := (*(sql.ColumnType))(v)
-	output := ""
-	// output += fmt.Sprintf("%q:%s", (*ct).Name(), (*ct).DatabaseTypeName())
-	output += fmt.Sprintf("%s", (*ct).DatabaseTypeName())
-	if l, ok := (*ct).Length(); ok {
-		output += fmt.Sprintf("(%d)", l)
-	} else if precision, scale, ok := (*ct).DecimalSize(); ok {
-		output += fmt.Sprintf("(%d,%d)", precision, scale)
-	}
-	return output
+	length, hasLength := (*ct).Length()
+	precision, scale, hasDecimal := (*ct).DecimalSize()
+	return formatTypeName((*ct).DatabaseTypeName(), length, hasLength, precision, scale, hasDecimal)
 }
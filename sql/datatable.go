@@ -0,0 +1,84 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DataTable : rows held in memory together with the column names and types of the query
+// values have the same types as DataReader.GetValues: string, float64, bool, int32, int64, time.Time or nil
+type DataTable struct {
+	Columns    []string
+	TypeNames  []string // as DataReader.GetDataTypeName
+	TypeNames2 []string // as DataReader.GetDataTypeName2
+	Rows       [][]interface{}
+}
+
+// LoadDataTable : read all remaining rows of a reader into a DataTable and close the reader
+func LoadDataTable(reader *DataReader) (*DataTable, error) {
+	defer reader.Close()
+	dt := &DataTable{
+		Columns:    reader.GetNames(),
+		TypeNames:  make([]string, reader.FieldCount()),
+		TypeNames2: make([]string, reader.FieldCount()),
+	}
+	for i := range dt.Columns {
+		dt.TypeNames[i] = reader.GetDataTypeName(i)
+		dt.TypeNames2[i] = reader.GetDataTypeName2(i)
+	}
+	for reader.Read() {
+		dt.Rows = append(dt.Rows, reader.GetValues())
+	}
+	if err := reader.Err(); err != nil {
+		return nil, err
+	}
+	return dt, nil
+}
+
+// RowCount : number of rows
+func (dt *DataTable) RowCount() int {
+	return len(dt.Rows)
+}
+
+// ColumnIndex : index of a column by name, -1 if not found
+func (dt *DataTable) ColumnIndex(name string) int {
+	for i, c := range dt.Columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Reader : new DataReader over the rows of the table, can be called any number of times
+func (dt *DataTable) Reader() *DataReader {
+	return &DataReader{
+		table:       dt,
+		names:       dt.Columns,
+		typeNames:   dt.TypeNames,
+		typeNames2:  dt.TypeNames2,
+		vals:        newScanValues(dt.TypeNames),
+		columnCount: len(dt.Columns),
+	}
+}
+
+// readTable : Read for a reader created by DataTable.Reader
+func (dr *DataReader) readTable() bool {
+	if dr.err != nil || dr.closed || dr.pos >= len(dr.table.Rows) {
+		return false
+	}
+	row := dr.table.Rows[dr.pos]
+	dr.pos++
+	for i, v := range dr.vals {
+		var src interface{}
+		if i < len(row) {
+			src = row[i]
+		}
+		if err := v.(sql.Scanner).Scan(src); err != nil {
+			dr.setErr(fmt.Errorf("column %s: %w", dr.names[i], err))
+			return false
+		}
+	}
+	dr.rowCount++
+	return true
+}
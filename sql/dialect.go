@@ -0,0 +1,53 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect : SQL flavour of a connector
+type Dialect int
+
+const (
+	DialectUnknown Dialect = iota
+	DialectMssql
+	DialectPostgres
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case DialectMssql:
+		return "mssql"
+	case DialectPostgres:
+		return "postgres"
+	default:
+		return "unknown"
+	}
+}
+
+// Placeholder : n-th query parameter, n = [1,n], return @p1 for mssql and $1 for postgres
+func (d Dialect) Placeholder(n int) string {
+	if d == DialectMssql {
+		return fmt.Sprintf("@p%d", n)
+	}
+	return fmt.Sprintf("$%d", n)
+}
+
+// QuoteIdent : quote a table or column name, dotted names are quoted part by part
+// e.g. dbo.register_user => [dbo].[register_user] for mssql, "dbo"."register_user" for postgres
+func (d Dialect) QuoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		if d == DialectMssql {
+			parts[i] = "[" + strings.Replace(p, "]", "]]", -1) + "]"
+		} else {
+			parts[i] = `"` + strings.Replace(p, `"`, `""`, -1) + `"`
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// Dialect : return the SQL flavour of the connector
+func (bc *baseConnector) Dialect() Dialect {
+	return bc.dialect
+}
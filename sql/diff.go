@@ -0,0 +1,437 @@
+package sql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// DiffKind : kind of a RowDiff
+type DiffKind int
+
+const (
+	OnlyInLeft DiffKind = iota
+	OnlyInRight
+	Changed
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case OnlyInLeft:
+		return "only-in-left"
+	case OnlyInRight:
+		return "only-in-right"
+	default:
+		return "changed"
+	}
+}
+
+// DiffOptions : options of CompareReaders and CompareQueries
+type DiffOptions struct {
+	Tolerance      float64  // numbers within this absolute difference are equal
+	TrimSpace      bool     // ignore leading and trailing spaces of text, e.g. CHAR padding
+	IgnoreCase     bool     // compare text case-insensitively
+	NormalizeText  bool     // compare text in Unicode NFC form
+	IgnoreColumns  []string // columns not compared
+	MaxDifferences int      // stop after this many differences, 0 means no limit
+}
+
+// ColumnDiff : one column of a changed row
+type ColumnDiff struct {
+	Column string
+	Left   interface{}
+	Right  interface{}
+}
+
+// RowDiff : one row that differs
+type RowDiff struct {
+	Kind    DiffKind
+	Key     []interface{} // key values of the row, from the side it was found
+	Columns []ColumnDiff  // changed columns, for Changed only
+}
+
+// DiffResult : outcome of a comparison
+type DiffResult struct {
+	LeftRows  int64
+	RightRows int64
+	Matched   int64 // rows equal on both sides
+	OnlyLeft  int64
+	OnlyRight int64
+	Changed   int64
+
+	LeftOnlyColumns  []string // columns not compared because the other side does not have them
+	RightOnlyColumns []string
+	Differences      []RowDiff
+	Truncated        bool // MaxDifferences reached, counts cover only the rows read so far
+}
+
+// Equal : true if both sides hold the same rows
+func (r *DiffResult) Equal() bool {
+	return r.OnlyLeft == 0 && r.OnlyRight == 0 && r.Changed == 0 && !r.Truncated
+}
+
+// CompareQueries : run a query on each connector and compare the results with CompareReaders
+// both queries must ORDER BY the key columns, use a binary collation for text keys on SQL Server
+// so both sides sort the same way
+func CompareQueries(left DbConnector, leftQuery string, right DbConnector, rightQuery string,
+	keyColumns []string, opts DiffOptions) (*DiffResult, error) {
+	lr, err := queryConn(left, leftQuery)
+	if err != nil {
+		return nil, fmt.Errorf("diff: left query: %w", err)
+	}
+	defer lr.Close()
+	rr, err := queryConn(right, rightQuery)
+	if err != nil {
+		return nil, fmt.Errorf("diff: right query: %w", err)
+	}
+	defer rr.Close()
+	return CompareReaders(lr, rr, keyColumns, opts)
+}
+
+// CompareReaders : stream two readers sorted by keyColumns and report rows only in left, only in right
+// and rows with changed columns. columns are matched by name, ignoring case.
+// values are compared by the declared type of their column: numbers numerically, dates chronologically
+// and anything else as text by byte order after normalisation.
+// an error is returned if a key column has a different kind of type on each side,
+// or if a reader is not sorted by key in that order
+func CompareReaders(left, right *DataReader, keyColumns []string, opts DiffOptions) (*DiffResult, error) {
+	if len(keyColumns) == 0 {
+		return nil, fmt.Errorf("diff: no key columns")
+	}
+	leftIndex := columnIndexes(left.GetNames())
+	rightIndex := columnIndexes(right.GetNames())
+
+	lk, err := keyIndexes(leftIndex, keyColumns, "left")
+	if err != nil {
+		return nil, err
+	}
+	rk, err := keyIndexes(rightIndex, keyColumns, "right")
+	if err != nil {
+		return nil, err
+	}
+	keyKinds := make([]valueKind, len(keyColumns))
+	for i, k := range keyColumns {
+		lt, rt := left.GetDataTypeName(lk[i]), right.GetDataTypeName(rk[i])
+		if typeKind(lt) != typeKind(rt) {
+			return nil, fmt.Errorf("diff: key column %q is %s on the left and %s on the right", k, lt, rt)
+		}
+		keyKinds[i] = typeKind(lt)
+	}
+
+	result := &DiffResult{}
+	ignore := columnIndexes(append(append([]string{}, keyColumns...), opts.IgnoreColumns...))
+	type pair struct {
+		name        string
+		left, right int
+		kind        valueKind
+	}
+	var compared []pair
+	for i, name := range left.GetNames() {
+		if _, ok := ignore[strings.ToLower(name)]; ok {
+			continue
+		}
+		if j, ok := rightIndex[strings.ToLower(name)]; ok {
+			kind := typeKind(left.GetDataTypeName(i))
+			if kind != typeKind(right.GetDataTypeName(j)) {
+				kind = kindAny
+			}
+			compared = append(compared, pair{name, i, j, kind})
+		} else {
+			result.LeftOnlyColumns = append(result.LeftOnlyColumns, name)
+		}
+	}
+	for _, name := range right.GetNames() {
+		_, ignored := ignore[strings.ToLower(name)]
+		if _, ok := leftIndex[strings.ToLower(name)]; !ok && !ignored {
+			result.RightOnlyColumns = append(result.RightOnlyColumns, name)
+		}
+	}
+
+	c := &comparer{opts: opts}
+	var lrow, rrow, lkey, rkey, lprev, rprev []interface{}
+	next := func(dr *DataReader, keys []int, prev []interface{}, side string, count *int64) ([]interface{}, []interface{}, error) {
+		if !dr.Read() {
+			return nil, nil, dr.Err()
+		}
+		*count++
+		row := dr.GetValues()
+		key := make([]interface{}, len(keys))
+		for i, k := range keys {
+			key[i] = row[k]
+		}
+		if prev != nil && c.compareKeys(keyKinds, prev, key) > 0 {
+			return nil, nil, fmt.Errorf("diff: %s rows are not sorted by key at %v after %v", side, key, prev)
+		}
+		return row, key, nil
+	}
+	if lrow, lkey, err = next(left, lk, nil, "left", &result.LeftRows); err != nil {
+		return nil, err
+	}
+	if rrow, rkey, err = next(right, rk, nil, "right", &result.RightRows); err != nil {
+		return nil, err
+	}
+
+	add := func(d RowDiff) bool {
+		result.Differences = append(result.Differences, d)
+		if opts.MaxDifferences > 0 && len(result.Differences) >= opts.MaxDifferences {
+			result.Truncated = true
+			return false
+		}
+		return true
+	}
+
+	for lrow != nil || rrow != nil {
+		cmp := 0
+		switch {
+		case lrow == nil:
+			cmp = 1
+		case rrow == nil:
+			cmp = -1
+		default:
+			cmp = c.compareKeys(keyKinds, lkey, rkey)
+		}
+
+		more := true
+		advanceLeft, advanceRight := cmp <= 0, cmp >= 0
+		switch {
+		case cmp < 0:
+			result.OnlyLeft++
+			more = add(RowDiff{Kind: OnlyInLeft, Key: lkey})
+		case cmp > 0:
+			result.OnlyRight++
+			more = add(RowDiff{Kind: OnlyInRight, Key: rkey})
+		default:
+			var cols []ColumnDiff
+			for _, p := range compared {
+				if !c.equal(p.kind, lrow[p.left], rrow[p.right]) {
+					cols = append(cols, ColumnDiff{Column: p.name, Left: lrow[p.left], Right: rrow[p.right]})
+				}
+			}
+			if len(cols) == 0 {
+				result.Matched++
+			} else {
+				result.Changed++
+				more = add(RowDiff{Kind: Changed, Key: lkey, Columns: cols})
+			}
+		}
+		if !more {
+			break
+		}
+
+		if advanceLeft {
+			lprev = lkey
+			if lrow, lkey, err = next(left, lk, lprev, "left", &result.LeftRows); err != nil {
+				return nil, err
+			}
+		}
+		if advanceRight {
+			rprev = rkey
+			if rrow, rkey, err = next(right, rk, rprev, "right", &result.RightRows); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+func columnIndexes(names []string) map[string]int {
+	output := map[string]int{}
+	for i, n := range names {
+		if _, ok := output[strings.ToLower(n)]; !ok {
+			output[strings.ToLower(n)] = i
+		}
+	}
+	return output
+}
+
+func keyIndexes(index map[string]int, keyColumns []string, side string) ([]int, error) {
+	output := make([]int, len(keyColumns))
+	for i, k := range keyColumns {
+		j, ok := index[strings.ToLower(k)]
+		if !ok {
+			return nil, fmt.Errorf("diff: key column %q not in %s result", k, side)
+		}
+		output[i] = j
+	}
+	return output, nil
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+// comparer : compare values from different drivers, e.g. int32 from SQL Server with "5" from a Postgres INT4
+type comparer struct {
+	opts DiffOptions
+}
+
+// valueKind : how values of a column are compared, from its declared type
+type valueKind int
+
+const (
+	kindText valueKind = iota
+	kindNumber
+	kindTime
+	kindAny // non-key column with a different kind of type on each side, compared by what the values parse as
+)
+
+// typeKind : kind of a type name as given by DataReader.GetDataTypeName
+func typeKind(typeName string) valueKind {
+	name := strings.ToUpper(typeName)
+	if i := strings.IndexByte(name, '('); i >= 0 {
+		name = name[:i]
+	}
+	switch strings.TrimSpace(name) {
+	case "TINYINT", "SMALLINT", "INT", "INTEGER", "BIGINT", "INT2", "INT4", "INT8",
+		"DECIMAL", "NUMERIC", "FLOAT", "FLOAT4", "FLOAT8", "REAL", "DOUBLE", "MONEY", "SMALLMONEY":
+		return kindNumber
+	case "DATE", "DATETIME", "DATETIME2", "SMALLDATETIME", "DATETIMEOFFSET", "TIMESTAMP", "TIMESTAMPTZ":
+		return kindTime // SQL Server TIMESTAMP is rowversion, its values do not parse as time and compare as text
+	}
+	return kindText
+}
+
+func (c *comparer) compareKeys(kinds []valueKind, a, b []interface{}) int {
+	for i := range a {
+		if r := c.compare(kinds[i], a[i], b[i]); r != 0 {
+			return r
+		}
+	}
+	return 0
+}
+
+// compare : nil first, then by the kind of the column, values that do not parse as that kind compare as text
+func (c *comparer) compare(kind valueKind, a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if kind == kindNumber || kind == kindAny {
+		if x, ok := toInt(a); ok {
+			if y, ok := toInt(b); ok {
+				switch {
+				case x < y:
+					return -1
+				case x > y:
+					return 1
+				}
+				return 0
+			}
+		}
+		if x, ok := toNumber(a); ok {
+			if y, ok := toNumber(b); ok {
+				switch {
+				case x < y:
+					return -1
+				case x > y:
+					return 1
+				}
+				return 0
+			}
+		}
+	}
+	if kind == kindTime || kind == kindAny {
+		if x, ok := toTime(a); ok {
+			if y, ok := toTime(b); ok {
+				switch {
+				case x.Before(y):
+					return -1
+				case x.After(y):
+					return 1
+				}
+				return 0
+			}
+		}
+	}
+	return strings.Compare(c.text(a), c.text(b))
+}
+
+func (c *comparer) equal(kind valueKind, a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if kind == kindNumber || kind == kindAny {
+		if x, ok := toInt(a); ok {
+			if y, ok := toInt(b); ok {
+				// the difference as uint64 is exact where x-y would overflow or a float64 would round
+				d := uint64(x - y)
+				if x < y {
+					d = uint64(y - x)
+				}
+				return float64(d) <= c.opts.Tolerance
+			}
+		}
+		if x, ok := toNumber(a); ok {
+			if y, ok := toNumber(b); ok {
+				return math.Abs(x-y) <= c.opts.Tolerance
+			}
+		}
+	}
+	return c.compare(kind, a, b) == 0
+}
+
+func (c *comparer) text(v interface{}) string {
+	s := fmt.Sprint(v)
+	if c.opts.TrimSpace {
+		s = strings.TrimSpace(s)
+	}
+	if c.opts.NormalizeText {
+		s = norm.NFC.String(s)
+	}
+	if c.opts.IgnoreCase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// toInt : integers and integer text, compared exactly as BIGINT beyond 2^53 does not fit a float64
+func toInt(v interface{}) (int64, bool) {
+	switch x := v.(type) {
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// toNumber : numbers and number text of any kind, see toInt
+func toNumber(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+			if t, err := time.Parse(layout, strings.TrimSpace(x)); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
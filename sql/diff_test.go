@@ -0,0 +1,157 @@
+package sql
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/churikawit/gosrc/sql/sqltest"
+)
+
+func TestCompareReaders(t *testing.T) {
+	// left looks like SQL Server output, right like Postgres output with INT4 read as text
+	left := &DataTable{
+		Columns:    []string{"id", "name", "amount", "note"},
+		TypeNames:  []string{"INT", "NVARCHAR", "DECIMAL", "NVARCHAR"},
+		TypeNames2: []string{"INT", "NVARCHAR(50)", "DECIMAL(10,2)", "NVARCHAR(50)"},
+		Rows: [][]interface{}{
+			{int32(1), "Somchai  ", 10.00, "x"},
+			{int32(2), "Malee", 20.00, "x"},
+			{int32(4), "Anan", 40.00, "x"},
+			{int32(10), "Niran", 100.00, "x"},
+		},
+	}
+	right := &DataTable{
+		Columns:    []string{"ID", "Name", "Amount", "extra"},
+		TypeNames:  []string{"INT4", "VARCHAR", "NUMERIC", "TEXT"},
+		TypeNames2: []string{"INT4", "VARCHAR(50)", "NUMERIC(10,2)", "TEXT"},
+		Rows: [][]interface{}{
+			{"1", "somchai", "10.001", "y"},
+			{"2", "Malee", "25.00", "y"},
+			{"3", "Chai", "30.00", "y"},
+			{"10", "Niran", "100", "y"},
+		},
+	}
+
+	opts := DiffOptions{Tolerance: 0.01, TrimSpace: true, IgnoreCase: true}
+	got, err := CompareReaders(left.Reader(), right.Reader(), []string{"id"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LeftRows != 4 || got.RightRows != 4 || got.Matched != 2 || got.OnlyLeft != 1 || got.OnlyRight != 1 || got.Changed != 1 {
+		t.Errorf("counts == %+v", got)
+	}
+	want := []RowDiff{
+		{Kind: Changed, Key: []interface{}{int32(2)}, Columns: []ColumnDiff{{"amount", 20.00, "25.00"}}},
+		{Kind: OnlyInRight, Key: []interface{}{"3"}},
+		{Kind: OnlyInLeft, Key: []interface{}{int32(4)}},
+	}
+	if !reflect.DeepEqual(got.Differences, want) {
+		t.Errorf("Differences == %+v, want %+v", got.Differences, want)
+	}
+	if !reflect.DeepEqual(got.LeftOnlyColumns, []string{"note"}) || !reflect.DeepEqual(got.RightOnlyColumns, []string{"extra"}) {
+		t.Errorf("only columns == %v %v", got.LeftOnlyColumns, got.RightOnlyColumns)
+	}
+
+	// sorted as text on the database side: "10" before "2"
+	right.Rows[1], right.Rows[3] = right.Rows[3], right.Rows[1]
+	if _, err := CompareReaders(left.Reader(), right.Reader(), []string{"id"}, opts); err == nil {
+		t.Errorf("CompareReaders with unsorted input expect error")
+	}
+}
+
+func TestCompareReadersTextKeys(t *testing.T) {
+	// text keys mixing numbers and letters, sorted by the database as text: "10" < "9" < "A1"
+	table := func(values ...string) *DataTable {
+		dt := &DataTable{Columns: []string{"code"}, TypeNames: []string{"VARCHAR"}, TypeNames2: []string{"VARCHAR(10)"}}
+		for _, v := range values {
+			dt.Rows = append(dt.Rows, []interface{}{v})
+		}
+		return dt
+	}
+	got, err := CompareReaders(table("10", "9", "A1").Reader(), table("10", "A1", "B").Reader(), []string{"code"}, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RowDiff{{Kind: OnlyInLeft, Key: []interface{}{"9"}}, {Kind: OnlyInRight, Key: []interface{}{"B"}}}
+	if got.Matched != 2 || !reflect.DeepEqual(got.Differences, want) {
+		t.Errorf("CompareReaders(text keys) == %+v, want 2 matched and %+v", got, want)
+	}
+
+	number := &DataTable{Columns: []string{"code"}, TypeNames: []string{"INT"}, TypeNames2: []string{"INT"},
+		Rows: [][]interface{}{{int32(9)}}}
+	if _, err := CompareReaders(table("9").Reader(), number.Reader(), []string{"code"}, DiffOptions{}); err == nil {
+		t.Errorf("CompareReaders with a text key on the left and a number key on the right expect error")
+	}
+}
+
+func TestCompareReadersBigint(t *testing.T) {
+	// 2^53 and 2^53+1 are the same float64
+	left := &DataTable{Columns: []string{"id", "ref"}, TypeNames: []string{"BIGINT", "BIGINT"}, TypeNames2: []string{"BIGINT", "BIGINT"},
+		Rows: [][]interface{}{
+			{int64(9007199254740992), int64(1)},
+			{int64(9007199254740993), int64(9007199254740993)},
+		}}
+	right := &DataTable{Columns: []string{"id", "ref"}, TypeNames: []string{"BIGINT", "BIGINT"}, TypeNames2: []string{"BIGINT", "BIGINT"},
+		Rows: [][]interface{}{
+			{int64(9007199254740993), int64(9007199254740992)},
+			{int64(9007199254740994), int64(1)},
+		}}
+	got, err := CompareReaders(left.Reader(), right.Reader(), []string{"id"}, DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RowDiff{
+		{Kind: OnlyInLeft, Key: []interface{}{int64(9007199254740992)}},
+		{Kind: Changed, Key: []interface{}{int64(9007199254740993)},
+			Columns: []ColumnDiff{{"ref", int64(9007199254740993), int64(9007199254740992)}}},
+		{Kind: OnlyInRight, Key: []interface{}{int64(9007199254740994)}},
+	}
+	if !reflect.DeepEqual(got.Differences, want) {
+		t.Errorf("Differences == %+v, want %+v", got.Differences, want)
+	}
+}
+
+func TestComparerNumbers(t *testing.T) {
+	cases := []struct {
+		a, b      interface{}
+		tolerance float64
+		want      int
+		wantEqual bool
+	}{
+		{int64(9007199254740992), int64(9007199254740993), 0, -1, false},
+		{"9007199254740993", int64(9007199254740993), 0, 0, true},
+		{int64(9007199254740993), int64(9007199254740992), 1, 1, true},
+		{int64(math.MaxInt64), int64(math.MinInt64), 1, 1, false},
+		{int32(2), "2.0", 0, 0, true},
+		{10.5, "10.501", 0.01, -1, true},
+	}
+	for _, c := range cases {
+		cmp := &comparer{opts: DiffOptions{Tolerance: c.tolerance}}
+		if got := cmp.compare(kindNumber, c.a, c.b); got != c.want {
+			t.Errorf("compare(%#v, %#v) == %d, want %d", c.a, c.b, got, c.want)
+		}
+		if got := cmp.equal(kindNumber, c.a, c.b); got != c.wantEqual {
+			t.Errorf("equal(%#v, %#v) with tolerance %v == %v, want %v", c.a, c.b, c.tolerance, got, c.wantEqual)
+		}
+	}
+}
+
+func TestCompareQueriesError(t *testing.T) {
+	fake := sqltest.New()
+	conn := NewPostgresConnector2(fake.DSN())
+	conn.DriverName = sqltest.DriverName
+	conn.SetLogger(NopLogger())
+	if err := conn.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseConnection()
+	errSyntax := errors.New("syntax error at or near \"form\"")
+	fake.ExpectQuery("select id form orders").WillReturnError(errSyntax)
+
+	_, err := CompareQueries(conn, "select id form orders", conn, "select id from orders", []string{"id"}, DiffOptions{})
+	if !errors.Is(err, errSyntax) {
+		t.Errorf("CompareQueries error == %v, want the query error", err)
+	}
+}
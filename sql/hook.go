@@ -0,0 +1,82 @@
+package sql
+
+import (
+	"strings"
+	"time"
+)
+
+// QueryEvent : one statement run through a connector, passed to every QueryHook
+type QueryEvent struct {
+	Label    string        // see QueryLabel
+	SQL      string        // query text
+	Args     []interface{} // query arguments
+	Start    time.Time     // time the statement was sent
+	Duration time.Duration // until Query or Exec returns, in AfterRows until the last row is read
+	Rows     int64         // NonQuery: rows affected, AfterRows: rows read, 0 in AfterQuery of a Query
+	HasRows  bool          // a Query that returned rows, AfterRows follows once they are read
+	Err      error         // in AfterRows the error met while reading, if any
+}
+
+// QueryHook : called around every Query and NonQuery of a connector
+// AfterQuery is called as soon as the statement returns, errors met while reading rows go to the logger
+type QueryHook interface {
+	BeforeQuery(e *QueryEvent)
+	AfterQuery(e *QueryEvent)
+}
+
+// RowsHook : QueryHook that also wants the rows of a Query,
+// AfterRows is called once when the reader is read to the end, fails or is closed
+type RowsHook interface {
+	AfterRows(e *QueryEvent)
+}
+
+// HookFuncs : build a QueryHook from functions, any may be nil
+type HookFuncs struct {
+	Before func(e *QueryEvent)
+	After  func(e *QueryEvent)
+	Rows   func(e *QueryEvent) // see RowsHook
+}
+
+func (h HookFuncs) BeforeQuery(e *QueryEvent) {
+	if h.Before != nil {
+		h.Before(e)
+	}
+}
+
+func (h HookFuncs) AfterQuery(e *QueryEvent) {
+	if h.After != nil {
+		h.After(e)
+	}
+}
+
+func (h HookFuncs) AfterRows(e *QueryEvent) {
+	if h.Rows != nil {
+		h.Rows(e)
+	}
+}
+
+const maxLabelLength = 60
+
+// QueryLabel : short name of a query used by metrics and slow query logs
+// a query starting with a comment "-- name: daily_report" is labelled "daily_report",
+// otherwise the label is the query text with whitespace collapsed, cut at 60 characters
+func QueryLabel(QueryString string) string {
+	s := strings.TrimSpace(QueryString)
+	if strings.HasPrefix(s, "--") {
+		line := s
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "--"))
+		if strings.HasPrefix(strings.ToLower(line), "name:") {
+			if name := strings.TrimSpace(line[len("name:"):]); name != "" {
+				return name
+			}
+		}
+	}
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxLabelLength {
+		s = string(r[:maxLabelLength]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,181 @@
+package sql
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Notification : message received from a postgres NOTIFY
+type Notification struct {
+	Channel string
+	Payload string
+	PID     int // backend process id of the sender
+}
+
+// ListenOptions : options of ListenWith, zero values use the defaults
+type ListenOptions struct {
+	MinReconnect time.Duration // first wait before reconnecting, default 10s
+	MaxReconnect time.Duration // longest wait between reconnect attempts, default 1m
+	PingInterval time.Duration // ping the connection when idle this long, default 90s
+	Buffer       int           // capacity of Subscription.C, default 32
+	// OnReconnect is called after the connection was lost and restored,
+	// notifications sent while disconnected are lost so callers may want to resync
+	OnReconnect func()
+}
+
+// Subscription : notifications of the listened channels, see PostgresConnector.Listen
+type Subscription struct {
+	// C delivers the notifications, it is closed by Close
+	C <-chan Notification
+
+	listener listener
+	done     chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+// listener : the part of pq.Listener a Subscription uses
+type listener interface {
+	Listen(channel string) error
+	Unlisten(channel string) error
+	Ping() error
+	Close() error
+	NotificationChannel() <-chan *pq.Notification
+}
+
+// newListener : pq.NewListener, replaced by tests
+var newListener = func(dsn string, minReconnect, maxReconnect time.Duration, callback pq.EventCallbackType) listener {
+	return pq.NewListener(dsn, minReconnect, maxReconnect, callback)
+}
+
+// Listen : LISTEN on channels with default options, the connection reconnects automatically
+//
+//	sub, err := conn.Listen("order_changed")
+//	defer sub.Close()
+//	for n := range sub.C {
+//		push(n.Payload)
+//	}
+func (conn *PostgresConnector) Listen(channels ...string) (*Subscription, error) {
+	return conn.ListenWith(ListenOptions{}, channels...)
+}
+
+// ListenWith : same as Listen with options
+// the listener uses its own connection, OpenConnection is not needed
+func (conn *PostgresConnector) ListenWith(opts ListenOptions, channels ...string) (*Subscription, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("listen: no channel")
+	}
+	opts = listenDefaults(opts)
+	dsn := conn.connectionString()
+	listener := newListener(dsn, opts.MinReconnect, opts.MaxReconnect, listenEvents(conn.logger(), dsn))
+	for _, ch := range channels {
+		if err := listener.Listen(ch); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+
+	c := make(chan Notification, opts.Buffer)
+	sub := &Subscription{C: c, listener: listener, done: make(chan struct{})}
+	sub.wg.Add(1)
+	go sub.run(c, opts)
+	return sub, nil
+}
+
+// listenDefaults : opts with the defaults of ListenOptions, MaxReconnect is never below MinReconnect
+func listenDefaults(opts ListenOptions) ListenOptions {
+	if opts.MinReconnect <= 0 {
+		opts.MinReconnect = 10 * time.Second
+	}
+	if opts.MaxReconnect < opts.MinReconnect {
+		opts.MaxReconnect = time.Minute
+		if opts.MaxReconnect < opts.MinReconnect {
+			opts.MaxReconnect = opts.MinReconnect
+		}
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = 90 * time.Second
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 32
+	}
+	return opts
+}
+
+// listenEvents : log the connection events of a listener
+func listenEvents(logger Logger, dsn string) pq.EventCallbackType {
+	return func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			logger.Log(LevelWarn, "postgres listen: disconnected", "dsn", MaskDSN(dsn), "error", err)
+		case pq.ListenerEventReconnected:
+			logger.Log(LevelInfo, "postgres listen: reconnected", "dsn", MaskDSN(dsn))
+		case pq.ListenerEventConnectionAttemptFailed:
+			logger.Log(LevelWarn, "postgres listen: reconnect failed", "dsn", MaskDSN(dsn), "error", err)
+		}
+	}
+}
+
+func (s *Subscription) run(c chan<- Notification, opts ListenOptions) {
+	defer s.wg.Done()
+	defer close(c)
+	ticker := time.NewTicker(opts.PingInterval)
+	defer ticker.Stop()
+	notify := s.listener.NotificationChannel()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case n, ok := <-notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// pq sends nil after a reconnect
+				if opts.OnReconnect != nil {
+					opts.OnReconnect()
+				}
+				continue
+			}
+			select {
+			case c <- Notification{Channel: n.Channel, Payload: n.Extra, PID: n.BePid}:
+			case <-s.done:
+				return
+			}
+		case <-ticker.C:
+			// a failed ping makes pq notice a dead connection and reconnect
+			go s.listener.Ping()
+		}
+	}
+}
+
+// Listen : add a channel to the subscription
+func (s *Subscription) Listen(channel string) error {
+	return s.listener.Listen(channel)
+}
+
+// Unlisten : remove a channel from the subscription
+func (s *Subscription) Unlisten(channel string) error {
+	return s.listener.Unlisten(channel)
+}
+
+// Close : stop listening and close C
+func (s *Subscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		err = s.listener.Close()
+		s.wg.Wait()
+	})
+	return err
+}
+
+// Notify : send a notification to every listener of channel
+func (conn *PostgresConnector) Notify(channel, payload string) error {
+	_, err := conn.exec("SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
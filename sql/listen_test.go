@@ -0,0 +1,233 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// fakeListener : pq.Listener without a server, notifications are sent by the test
+type fakeListener struct {
+	mu        sync.Mutex
+	channels  []string
+	pings     int
+	closed    bool
+	listenErr error
+	notify    chan *pq.Notification
+}
+
+func (l *fakeListener) Listen(channel string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.listenErr != nil {
+		return l.listenErr
+	}
+	l.channels = append(l.channels, channel)
+	return nil
+}
+
+func (l *fakeListener) Unlisten(channel string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, c := range l.channels {
+		if c == channel {
+			l.channels = append(l.channels[:i], l.channels[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("not listening on %q", channel)
+}
+
+func (l *fakeListener) Ping() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pings++
+	return nil
+}
+
+func (l *fakeListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return errors.New("listener already closed")
+	}
+	l.closed = true
+	close(l.notify)
+	return nil
+}
+
+func (l *fakeListener) NotificationChannel() <-chan *pq.Notification { return l.notify }
+
+func (l *fakeListener) state() (channels []string, pings int, closed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.channels...), l.pings, l.closed
+}
+
+// useFakeListener : make ListenWith use fake, return the reconnect bounds it was given
+func useFakeListener(t *testing.T, fake *fakeListener) *[2]time.Duration {
+	var bounds [2]time.Duration
+	saved := newListener
+	newListener = func(dsn string, minReconnect, maxReconnect time.Duration, callback pq.EventCallbackType) listener {
+		bounds = [2]time.Duration{minReconnect, maxReconnect}
+		return fake
+	}
+	t.Cleanup(func() { newListener = saved })
+	return &bounds
+}
+
+func TestListenDefaults(t *testing.T) {
+	cases := []struct {
+		in   ListenOptions
+		want ListenOptions
+	}{
+		{ListenOptions{}, ListenOptions{MinReconnect: 10 * time.Second, MaxReconnect: time.Minute, PingInterval: 90 * time.Second, Buffer: 32}},
+		{ListenOptions{MinReconnect: time.Second, MaxReconnect: 5 * time.Second, PingInterval: time.Second, Buffer: 1},
+			ListenOptions{MinReconnect: time.Second, MaxReconnect: 5 * time.Second, PingInterval: time.Second, Buffer: 1}},
+		{ListenOptions{MinReconnect: 30 * time.Second, MaxReconnect: 20 * time.Second},
+			ListenOptions{MinReconnect: 30 * time.Second, MaxReconnect: time.Minute, PingInterval: 90 * time.Second, Buffer: 32}},
+		{ListenOptions{MinReconnect: 2 * time.Minute},
+			ListenOptions{MinReconnect: 2 * time.Minute, MaxReconnect: 2 * time.Minute, PingInterval: 90 * time.Second, Buffer: 32}},
+	}
+
+	for _, c := range cases {
+		if got := listenDefaults(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("listenDefaults(%+v) == %+v, want %+v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestListenEvents(t *testing.T) {
+	var logged []string
+	logger := LoggerFunc(func(level Level, msg string, keyvals ...interface{}) {
+		logged = append(logged, fmt.Sprint(level, " ", msg, " ", keyvals))
+	})
+	callback := listenEvents(logger, "postgres://bot:secret@db/linebot")
+	callback(pq.ListenerEventConnected, nil)
+	callback(pq.ListenerEventDisconnected, errors.New("connection reset"))
+	callback(pq.ListenerEventConnectionAttemptFailed, errors.New("connection refused"))
+	callback(pq.ListenerEventReconnected, nil)
+
+	want := []string{"WARN postgres listen: disconnected", "WARN postgres listen: reconnect failed", "INFO postgres listen: reconnected"}
+	if len(logged) != len(want) {
+		t.Fatalf("logged == %q, want %q", logged, want)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(logged[i], w) || strings.Contains(logged[i], "secret") {
+			t.Errorf("logged[%d] == %q, want %q with the password masked", i, logged[i], w)
+		}
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	fake := &fakeListener{notify: make(chan *pq.Notification)}
+	bounds := useFakeListener(t, fake)
+	reconnects := make(chan struct{}, 1)
+	opts := ListenOptions{MinReconnect: time.Second, PingInterval: time.Millisecond, OnReconnect: func() { reconnects <- struct{}{} }}
+
+	conn := NewPostgresConnector2("postgres://bot:secret@db/linebot")
+	sub, err := conn.ListenWith(opts, "order_changed", "stock_changed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *bounds != [2]time.Duration{time.Second, time.Minute} {
+		t.Errorf("reconnect bounds == %v, want [1s 1m0s]", *bounds)
+	}
+	if err := sub.Unlisten("stock_changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Listen("price_changed"); err != nil {
+		t.Fatal(err)
+	}
+	if channels, _, _ := fake.state(); !reflect.DeepEqual(channels, []string{"order_changed", "price_changed"}) {
+		t.Errorf("channels == %v", channels)
+	}
+
+	// nil is how pq reports a reconnect, it is not delivered
+	fake.notify <- &pq.Notification{Channel: "order_changed", Extra: "1", BePid: 7}
+	fake.notify <- nil
+	fake.notify <- &pq.Notification{Channel: "price_changed", Extra: "2", BePid: 8}
+	want := []Notification{{"order_changed", "1", 7}, {"price_changed", "2", 8}}
+	for _, w := range want {
+		if got := <-sub.C; got != w {
+			t.Errorf("received %+v, want %+v", got, w)
+		}
+	}
+	select {
+	case <-reconnects:
+	default:
+		t.Error("OnReconnect not called")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for _, pings, _ := fake.state(); pings == 0 && time.Now().Before(deadline); _, pings, _ = fake.state() {
+		time.Sleep(time.Millisecond)
+	}
+	if _, pings, _ := fake.state(); pings == 0 {
+		t.Error("idle listener never pinged")
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("C open after Close")
+	}
+	if err := sub.Close(); err != nil {
+		t.Errorf("second Close() == %v, want nil", err)
+	}
+}
+
+func TestListenError(t *testing.T) {
+	fake := &fakeListener{notify: make(chan *pq.Notification), listenErr: errors.New("permission denied")}
+	useFakeListener(t, fake)
+	conn := NewPostgresConnector2("postgres://bot:secret@db/linebot")
+
+	if _, err := conn.ListenWith(ListenOptions{}, "order_changed"); err == nil {
+		t.Error("ListenWith with a failing LISTEN == nil error, want error")
+	}
+	if _, _, closed := fake.state(); !closed {
+		t.Error("listener not closed after a failed LISTEN")
+	}
+	if _, err := conn.Listen(); err == nil {
+		t.Error("Listen() without channels == nil error, want error")
+	}
+}
+
+// TestListenPostgres : needs a postgres server, set GOSRC_POSTGRES_DSN to run it
+func TestListenPostgres(t *testing.T) {
+	dsn := os.Getenv("GOSRC_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("GOSRC_POSTGRES_DSN not set")
+	}
+	conn := NewPostgresConnector2(dsn)
+	conn.SetLogger(NopLogger())
+	if err := conn.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseConnection()
+
+	sub, err := conn.ListenWith(ListenOptions{MinReconnect: time.Second}, "gosrc_listen_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if err := conn.Notify("gosrc_listen_test", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-sub.C:
+		if n.Channel != "gosrc_listen_test" || n.Payload != "hello" {
+			t.Errorf("received %+v, want hello on gosrc_listen_test", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no notification within 5s")
+	}
+}
@@ -0,0 +1,163 @@
+package sql
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets : latency histogram buckets in seconds, same as the Prometheus client default
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// QueryStats : counters and latency histogram of one query label
+type QueryStats struct {
+	Label        string
+	Count        uint64
+	Errors       uint64
+	Rows         uint64
+	DurationSum  time.Duration
+	BucketCounts []uint64 // cumulative count per bucket of MetricsCollector.Buckets
+}
+
+// MetricsCollector : QueryHook that keeps Prometheus style counters and latency histograms per query label
+//
+//	metrics := sql.NewMetricsCollector()
+//	conn.AddHook(metrics)
+//	http.Handle("/metrics", metrics)
+type MetricsCollector struct {
+	Namespace string              // metric name prefix, default "sql"
+	Buckets   []float64           // upper bounds in seconds, default DefaultBuckets
+	LabelFunc func(string) string // map query text to label, default QueryLabel
+
+	mu    sync.Mutex
+	stats map[string]*QueryStats
+}
+
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{
+		Namespace: "sql",
+		Buckets:   DefaultBuckets,
+		LabelFunc: QueryLabel,
+		stats:     map[string]*QueryStats{},
+	}
+}
+
+func (mc *MetricsCollector) BeforeQuery(e *QueryEvent) {}
+
+// AfterQuery : count NonQuery and failed Query, a Query that returned rows is counted by AfterRows
+func (mc *MetricsCollector) AfterQuery(e *QueryEvent) {
+	if !e.HasRows {
+		mc.record(e)
+	}
+}
+
+// AfterRows : count a Query with the rows read and the time spent reading them
+func (mc *MetricsCollector) AfterRows(e *QueryEvent) {
+	mc.record(e)
+}
+
+func (mc *MetricsCollector) record(e *QueryEvent) {
+	label := e.Label
+	if mc.LabelFunc != nil {
+		label = mc.LabelFunc(e.SQL)
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.stats == nil {
+		mc.stats = map[string]*QueryStats{}
+	}
+	st, ok := mc.stats[label]
+	if !ok {
+		st = &QueryStats{Label: label, BucketCounts: make([]uint64, len(mc.Buckets))}
+		mc.stats[label] = st
+	}
+	st.Count++
+	if e.Err != nil {
+		st.Errors++
+	}
+	if e.Rows > 0 {
+		st.Rows += uint64(e.Rows)
+	}
+	st.DurationSum += e.Duration
+	seconds := e.Duration.Seconds()
+	for i, le := range mc.Buckets {
+		if seconds <= le {
+			st.BucketCounts[i]++
+		}
+	}
+}
+
+// Snapshot : copy of the statistics of every label, sorted by label
+func (mc *MetricsCollector) Snapshot() []QueryStats {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	output := make([]QueryStats, 0, len(mc.stats))
+	for _, st := range mc.stats {
+		c := *st
+		c.BucketCounts = append([]uint64(nil), st.BucketCounts...)
+		output = append(output, c)
+	}
+	sort.Slice(output, func(i, j int) bool { return output[i].Label < output[j].Label })
+	return output
+}
+
+// Reset : drop all collected statistics
+func (mc *MetricsCollector) Reset() {
+	mc.mu.Lock()
+	mc.stats = map[string]*QueryStats{}
+	mc.mu.Unlock()
+}
+
+// WritePrometheus : write all metrics in the Prometheus text exposition format
+func (mc *MetricsCollector) WritePrometheus(w io.Writer) error {
+	ns := mc.Namespace
+	if ns == "" {
+		ns = "sql"
+	}
+	snapshot := mc.Snapshot()
+
+	var sb strings.Builder
+	counter := func(name, help string, value func(QueryStats) uint64) {
+		fmt.Fprintf(&sb, "# HELP %s_%s %s\n# TYPE %s_%s counter\n", ns, name, help, ns, name)
+		for _, st := range snapshot {
+			fmt.Fprintf(&sb, "%s_%s{query=%s} %d\n", ns, name, quoteLabel(st.Label), value(st))
+		}
+	}
+	counter("queries_total", "Number of queries run.", func(st QueryStats) uint64 { return st.Count })
+	counter("query_errors_total", "Number of queries that returned an error.", func(st QueryStats) uint64 { return st.Errors })
+	counter("query_rows_total", "Number of rows affected or read.", func(st QueryStats) uint64 { return st.Rows })
+
+	name := ns + "_query_duration_seconds"
+	fmt.Fprintf(&sb, "# HELP %s Query latency in seconds.\n# TYPE %s histogram\n", name, name)
+	for _, st := range snapshot {
+		label := quoteLabel(st.Label)
+		for i, le := range mc.Buckets {
+			fmt.Fprintf(&sb, "%s_bucket{query=%s,le=\"%s\"} %d\n", name, label,
+				strconv.FormatFloat(le, 'g', -1, 64), st.BucketCounts[i])
+		}
+		fmt.Fprintf(&sb, "%s_bucket{query=%s,le=\"+Inf\"} %d\n", name, label, st.Count)
+		fmt.Fprintf(&sb, "%s_sum{query=%s} %s\n", name, label,
+			strconv.FormatFloat(st.DurationSum.Seconds(), 'g', -1, 64))
+		fmt.Fprintf(&sb, "%s_count{query=%s} %d\n", name, label, st.Count)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// ServeHTTP : expose the metrics to a Prometheus scraper
+func (mc *MetricsCollector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	mc.WritePrometheus(w)
+}
+
+func quoteLabel(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
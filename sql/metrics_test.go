@@ -0,0 +1,102 @@
+package sql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/churikawit/gosrc/sql/sqltest"
+)
+
+func TestQueryLabel(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"-- name: daily_report\nselect * from sales", "daily_report"},
+		{"select *\n\tfrom   register_user\n where bot_id=$1", "select * from register_user where bot_id=$1"},
+		{"-- comment only\nselect 1", "-- comment only select 1"},
+		{strings.Repeat("x", 70), strings.Repeat("x", 60) + "..."},
+	}
+
+	for _, c := range cases {
+		got := QueryLabel(c.in)
+		if got != c.want {
+			t.Errorf("QueryLabel(%q) == %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestMetricsCollector(t *testing.T) {
+	mc := NewMetricsCollector()
+	mc.AfterQuery(&QueryEvent{SQL: "-- name: q1\nselect 1", Duration: 3 * time.Millisecond, Rows: 1})
+	mc.AfterQuery(&QueryEvent{SQL: "-- name: q1\nselect 1", Duration: 2 * time.Second, Rows: 2})
+	mc.AfterQuery(&QueryEvent{SQL: "-- name: q2\nselect 2", Err: errors.New("boom")})
+
+	stats := mc.Snapshot()
+	if len(stats) != 2 || stats[0].Label != "q1" || stats[0].Count != 2 || stats[0].Rows != 3 || stats[1].Errors != 1 {
+		t.Fatalf("Snapshot() == %+v", stats)
+	}
+
+	var sb strings.Builder
+	if err := mc.WritePrometheus(&sb); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`sql_queries_total{query="q1"} 2`,
+		`sql_query_errors_total{query="q2"} 1`,
+		`sql_query_duration_seconds_bucket{query="q1",le="0.005"} 1`,
+		`sql_query_duration_seconds_bucket{query="q1",le="2.5"} 2`,
+		`sql_query_duration_seconds_count{query="q1"} 2`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("WritePrometheus() missing %q\n%s", want, sb.String())
+		}
+	}
+}
+
+func TestMetricsCollectorRows(t *testing.T) {
+	fake := sqltest.New()
+	conn := NewPostgresConnector2(fake.DSN())
+	conn.DriverName = sqltest.DriverName
+	conn.SetLogger(NopLogger())
+	if err := conn.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseConnection()
+	mc := NewMetricsCollector()
+	conn.AddHook(mc)
+
+	fake.ExpectQuery("-- name: sales\nselect doc_no from sales").
+		WillReturnRows(sqltest.NewRows(sqltest.Column{Name: "doc_no", TypeName: "VARCHAR"}).AddRow("S001").AddRow("S002").AddRow("S003"))
+	fake.ExpectExec("-- name: paid\nupdate sales set paid = true").WillReturnResult(0, 4)
+	fake.ExpectQuery("-- name: broken\nselect x").WillReturnError(errors.New("no column x"))
+
+	reader := conn.Query("-- name: sales\nselect doc_no from sales")
+	if reader == nil {
+		t.Fatal("Query returned nil")
+	}
+	if stats := mc.Snapshot(); len(stats) != 0 {
+		t.Errorf("Snapshot() before reading == %+v, want nothing", stats)
+	}
+	for reader.Read() {
+	}
+	reader.Close()
+	if err := conn.NonQuery("-- name: paid\nupdate sales set paid = true"); err != nil {
+		t.Fatal(err)
+	}
+	if conn.Query("-- name: broken\nselect x") != nil {
+		t.Fatal("Query with an error returned a reader")
+	}
+
+	want := map[string][3]uint64{"broken": {1, 1, 0}, "paid": {1, 0, 4}, "sales": {1, 0, 3}}
+	stats := mc.Snapshot()
+	for _, st := range stats {
+		if got := [3]uint64{st.Count, st.Errors, st.Rows}; got != want[st.Label] {
+			t.Errorf("%s count, errors, rows == %v, want %v", st.Label, got, want[st.Label])
+		}
+	}
+	if len(stats) != len(want) {
+		t.Errorf("Snapshot() == %+v", stats)
+	}
+}
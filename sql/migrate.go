@@ -0,0 +1,420 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration : one versioned schema change
+//
+// files are named <version>_<name>[.<dialect>].<up|down>.sql, e.g.
+//
+//	0001_create_register_user.up.sql
+//	0001_create_register_user.down.sql
+//	0002_add_index.mssql.up.sql
+//	0002_add_index.postgres.up.sql
+//
+// a file for a dialect (mssql, postgres) wins over a file without dialect, files of other dialects are ignored
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus : state of one migration, see Migrator.Status
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Missing   bool // applied to the database but no file found
+}
+
+// LoadMigrations : read the migrations for a dialect from the root of fsys, sorted by version
+func LoadMigrations(fsys fs.FS, dialect Dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	type script struct {
+		text     string
+		specific bool
+	}
+	byVersion := map[int64]*Migration{}
+	scripts := map[string]script{}
+
+	for _, entry := range entries {
+		filename := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(filename, ".sql") {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(filename, ".sql"), ".")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>[.<dialect>].<up|down>.sql", filename)
+		}
+		direction := parts[len(parts)-1]
+		if direction != "up" && direction != "down" {
+			return nil, fmt.Errorf("migration %s: expect .up.sql or .down.sql", filename)
+		}
+		specific := len(parts) == 3
+		if specific && parts[1] != dialect.String() {
+			continue
+		}
+
+		index := strings.IndexByte(parts[0], '_')
+		if index <= 0 {
+			return nil, fmt.Errorf("migration %s: missing version", filename)
+		}
+		version, err := strconv.ParseInt(parts[0][:index], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", filename, err)
+		}
+		name := parts[0][index+1:]
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %s: version %d is already used by %q", filename, version, m.Name)
+		}
+
+		key := fmt.Sprintf("%d.%s", version, direction)
+		if prev, ok := scripts[key]; ok && (prev.specific || !specific) {
+			if prev.specific == specific {
+				return nil, fmt.Errorf("migration %s: duplicate %s script for version %d", filename, direction, version)
+			}
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Clean(filename))
+		if err != nil {
+			return nil, err
+		}
+		scripts[key] = script{text: string(data), specific: specific}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	output := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s: missing up script", m.Version, m.Name)
+		}
+		output = append(output, *m)
+	}
+	sort.Slice(output, func(i, j int) bool { return output[i].Version < output[j].Version })
+	return output, nil
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+// Migrator : apply and roll back migrations, keeping history in a table
+// a database lock is held while migrating so two instances never migrate at the same time
+//
+//	//go:embed migrations/*.sql
+//	var files embed.FS
+//
+//	sub, _ := fs.Sub(files, "migrations")
+//	m := sql.NewMigrator(conn, sub)
+//	applied, err := m.Migrate()
+type Migrator struct {
+	Table  string // history table, default "schema_migrations"
+	Logger Logger // nil means the package default logger
+
+	conn   DbConnector
+	source fs.FS
+}
+
+func NewMigrator(conn DbConnector, source fs.FS) *Migrator {
+	return &Migrator{Table: "schema_migrations", conn: conn, source: source}
+}
+
+func (m *Migrator) logger() Logger {
+	if m.Logger != nil {
+		return m.Logger
+	}
+	return defaultLogger
+}
+
+// Migrate : apply every pending migration, return number of migrations applied
+func (m *Migrator) Migrate() (int, error) {
+	return m.MigrateTo(-1)
+}
+
+// MigrateTo : apply pending migrations up to and including version, version < 0 means all
+func (m *Migrator) MigrateTo(version int64) (int, error) {
+	count := 0
+	err := m.withLock(func(ctx context.Context, c *sql.Conn, migrations []Migration, applied map[int64]MigrationStatus) error {
+		for _, mg := range migrations {
+			if version >= 0 && mg.Version > version {
+				break
+			}
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			m.logger().Log(LevelInfo, "migrate: up", "version", mg.Version, "name", mg.Name)
+			insert := fmt.Sprintf("insert into %s(version, name) values (%s, %s)",
+				m.conn.Dialect().QuoteIdent(m.Table), m.conn.Dialect().Placeholder(1), m.conn.Dialect().Placeholder(2))
+			err := m.run(ctx, c, mg.Up, insert, mg.Version, mg.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mg.Version, mg.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Rollback : undo the last steps applied migrations, return number of migrations rolled back
+func (m *Migrator) Rollback(steps int) (int, error) {
+	count := 0
+	err := m.withLock(func(ctx context.Context, c *sql.Conn, migrations []Migration, applied map[int64]MigrationStatus) error {
+		byVersion := map[int64]Migration{}
+		for _, mg := range migrations {
+			byVersion[mg.Version] = mg
+		}
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, v := range versions {
+			if count >= steps {
+				break
+			}
+			mg, ok := byVersion[v]
+			if !ok {
+				return fmt.Errorf("migration %d_%s down: file not found", v, applied[v].Name)
+			}
+			if strings.TrimSpace(mg.Down) == "" {
+				return fmt.Errorf("migration %d_%s down: no down script", v, mg.Name)
+			}
+			m.logger().Log(LevelInfo, "migrate: down", "version", mg.Version, "name", mg.Name)
+			remove := fmt.Sprintf("delete from %s where version = %s",
+				m.conn.Dialect().QuoteIdent(m.Table), m.conn.Dialect().Placeholder(1))
+			err := m.run(ctx, c, mg.Down, remove, mg.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mg.Version, mg.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status : every known migration, sorted by version, with whether it has been applied
+// the history table is only read, no lock is taken and the table is not created
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(m.source, m.conn.Dialect())
+	if err != nil {
+		return nil, err
+	}
+	db := m.conn.DB()
+	if db == nil {
+		return nil, ErrNotOpen
+	}
+
+	ctx := context.Background()
+	applied := map[int64]MigrationStatus{}
+	exists, err := m.tableExists(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: find %s: %w", m.Table, err)
+	}
+	if exists {
+		if applied, err = m.readApplied(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+
+	var output []MigrationStatus
+	for _, mg := range migrations {
+		st, ok := applied[mg.Version]
+		if !ok {
+			st = MigrationStatus{Version: mg.Version, Name: mg.Name}
+		}
+		output = append(output, st)
+		delete(applied, mg.Version)
+	}
+	for _, st := range applied {
+		st.Missing = true
+		output = append(output, st)
+	}
+	sort.Slice(output, func(i, j int) bool { return output[i].Version < output[j].Version })
+	return output, nil
+}
+
+// run : run a script batch by batch and update the history table in one transaction
+func (m *Migrator) run(ctx context.Context, c *sql.Conn, script, history string, args ...interface{}) error {
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for i, b := range SplitScript(script, m.conn.Dialect()) {
+		for j := 0; j < b.Count; j++ {
+			if _, err = tx.ExecContext(ctx, b.SQL); err != nil {
+				tx.Rollback()
+				return &ScriptError{Batch: i + 1, StartLine: b.StartLine, EndLine: b.EndLine, SQL: b.SQL, Err: err}
+			}
+		}
+	}
+	if _, err = tx.ExecContext(ctx, history, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type migrateFunc func(ctx context.Context, c *sql.Conn, migrations []Migration, applied map[int64]MigrationStatus) error
+
+// withLock : take the migration lock on a dedicated connection, make sure the history table exists and call fn
+func (m *Migrator) withLock(fn migrateFunc) error {
+	dialect := m.conn.Dialect()
+	migrations, err := LoadMigrations(m.source, dialect)
+	if err != nil {
+		return err
+	}
+	db := m.conn.DB()
+	if db == nil {
+		return ErrNotOpen
+	}
+
+	ctx := context.Background()
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err = m.lock(ctx, c); err != nil {
+		return fmt.Errorf("migrate: lock: %w", err)
+	}
+	defer m.unlock(ctx, c)
+
+	if _, err = c.ExecContext(ctx, m.createTableSQL()); err != nil {
+		return fmt.Errorf("migrate: create %s: %w", m.Table, err)
+	}
+
+	applied, err := m.readApplied(ctx, c)
+	if err != nil {
+		return err
+	}
+	return fn(ctx, c, migrations, applied)
+}
+
+type contextQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// readApplied : rows of the history table by version
+func (m *Migrator) readApplied(ctx context.Context, q contextQueryer) (map[int64]MigrationStatus, error) {
+	applied := map[int64]MigrationStatus{}
+	rows, err := q.QueryContext(ctx, fmt.Sprintf("select version, name, applied_at from %s", m.conn.Dialect().QuoteIdent(m.Table)))
+	if err != nil {
+		return nil, fmt.Errorf("migrate: read %s: %w", m.Table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		st := MigrationStatus{Applied: true}
+		if err = rows.Scan(&st.Version, &st.Name, &st.AppliedAt); err != nil {
+			return nil, fmt.Errorf("migrate: read %s: %w", m.Table, err)
+		}
+		applied[st.Version] = st
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("migrate: read %s: %w", m.Table, err)
+	}
+	return applied, nil
+}
+
+// tableExists : whether the history table exists in the current schema
+func (m *Migrator) tableExists(ctx context.Context, q contextQueryer) (bool, error) {
+	QueryString := "select count(*) from information_schema.tables where table_schema = current_schema() and table_name = $1"
+	if m.conn.Dialect() == DialectMssql {
+		QueryString = "select count(*) from INFORMATION_SCHEMA.TABLES where TABLE_SCHEMA = schema_name() and TABLE_NAME = @p1"
+	}
+	rows, err := q.QueryContext(ctx, QueryString, m.Table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	var count int64
+	if rows.Next() {
+		if err = rows.Scan(&count); err != nil {
+			return false, err
+		}
+	}
+	return count > 0, rows.Err()
+}
+
+func (m *Migrator) createTableSQL() string {
+	table := m.conn.Dialect().QuoteIdent(m.Table)
+	if m.conn.Dialect() == DialectMssql {
+		return fmt.Sprintf(`if object_id(N'%s', N'U') is null
+			create table %s (
+				version bigint not null primary key,
+				name nvarchar(255) not null,
+				applied_at datetime2 not null default sysutcdatetime()
+			)`, strings.Replace(m.Table, "'", "''", -1), table)
+	}
+	return fmt.Sprintf(`create table if not exists %s (
+			version bigint not null primary key,
+			name varchar(255) not null,
+			applied_at timestamptz not null default now()
+		)`, table)
+}
+
+// lockKey : advisory lock id derived from the history table name
+func (m *Migrator) lockKey() int64 {
+	return int64(crc32.ChecksumIEEE([]byte("gosrc.migrate." + m.Table)))
+}
+
+func (m *Migrator) lock(ctx context.Context, c *sql.Conn) error {
+	switch m.conn.Dialect() {
+	case DialectPostgres:
+		_, err := c.ExecContext(ctx, "select pg_advisory_lock($1)", m.lockKey())
+		return err
+	case DialectMssql:
+		var result int
+		err := c.QueryRowContext(ctx, `declare @result int
+			exec @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = -1
+			select @result`, fmt.Sprint(m.lockKey())).Scan(&result)
+		if err != nil {
+			return err
+		}
+		if result < 0 {
+			return fmt.Errorf("sp_getapplock returned %d", result)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported dialect %s", m.conn.Dialect())
+	}
+}
+
+func (m *Migrator) unlock(ctx context.Context, c *sql.Conn) {
+	var err error
+	switch m.conn.Dialect() {
+	case DialectPostgres:
+		_, err = c.ExecContext(ctx, "select pg_advisory_unlock($1)", m.lockKey())
+	case DialectMssql:
+		_, err = c.ExecContext(ctx, "exec sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", fmt.Sprint(m.lockKey()))
+	}
+	if err != nil {
+		m.logger().Log(LevelWarn, "migrate: unlock failed", "error", err)
+	}
+}
@@ -0,0 +1,129 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/churikawit/gosrc/sql/sqltest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_create_user.up.sql":          {Data: []byte("create table users(id int)")},
+		"0001_create_user.down.sql":        {Data: []byte("drop table users")},
+		"0002_add_index.up.sql":            {Data: []byte("create index generic")},
+		"0002_add_index.mssql.up.sql":      {Data: []byte("create index mssql")},
+		"0002_add_index.postgres.down.sql": {Data: []byte("drop index postgres")},
+		"0010_seed.postgres.up.sql":        {Data: []byte("insert postgres")},
+		"README.md":                        {Data: []byte("ignored")},
+	}
+
+	cases := []struct {
+		dialect  Dialect
+		versions []int64
+		up2      string
+		down2    string
+	}{
+		{DialectMssql, []int64{1, 2}, "create index mssql", ""},
+		{DialectPostgres, []int64{1, 2, 10}, "create index generic", "drop index postgres"},
+	}
+
+	for _, c := range cases {
+		got, err := LoadMigrations(fsys, c.dialect)
+		if err != nil {
+			t.Fatalf("LoadMigrations(%s) error %v", c.dialect, err)
+		}
+		if len(got) != len(c.versions) {
+			t.Fatalf("LoadMigrations(%s) == %d migrations, want %d", c.dialect, len(got), len(c.versions))
+		}
+		for i, v := range c.versions {
+			if got[i].Version != v {
+				t.Errorf("LoadMigrations(%s)[%d].Version == %d, want %d", c.dialect, i, got[i].Version, v)
+			}
+		}
+		if got[1].Name != "add_index" || got[1].Up != c.up2 || got[1].Down != c.down2 {
+			t.Errorf("LoadMigrations(%s)[1] == %+v", c.dialect, got[1])
+		}
+	}
+}
+
+func TestLoadMigrationsError(t *testing.T) {
+	cases := []fstest.MapFS{
+		{"create_user.up.sql": {Data: []byte("x")}},
+		{"0001_a.sideways.sql": {Data: []byte("x")}},
+		{"0001_a.down.sql": {Data: []byte("x")}},
+		{"0001_a.up.sql": {Data: []byte("x")}, "0001_b.up.mssql.sql": {Data: []byte("x")}},
+		{"0001_a.up.sql": {Data: []byte("x")}, "0001_b.down.sql": {Data: []byte("x")}},
+	}
+	for i, c := range cases {
+		if _, err := LoadMigrations(c, DialectMssql); err == nil {
+			t.Errorf("LoadMigrations(case %d) expect error", i)
+		}
+	}
+}
+
+func TestMigratorStatus(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_create_user.up.sql": {Data: []byte("create table users(id int)")},
+		"0002_add_index.up.sql":   {Data: []byte("create index ix on users(id)")},
+	}
+	applied := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		exists  int64
+		applied []int64
+		want    string
+	}{
+		{0, nil, "1:false 2:false"},
+		{1, []int64{1, 7}, "1:true 2:false 7:missing"},
+	}
+	for _, c := range cases {
+		fake := sqltest.New()
+		conn := NewPostgresConnector2(fake.DSN())
+		conn.DriverName = sqltest.DriverName
+		conn.SetLogger(NopLogger())
+		if err := conn.OpenConnection(); err != nil {
+			t.Fatal(err)
+		}
+		fake.ExpectQueryRegexp(`^select count\(\*\) from information_schema\.tables `).
+			WithArgs("schema_migrations").
+			WillReturnRows(sqltest.NewRows(sqltest.Column{Name: "count", TypeName: "INT8"}).AddRow(c.exists))
+		if c.exists > 0 {
+			rows := sqltest.NewRows(
+				sqltest.Column{Name: "version", TypeName: "INT8"},
+				sqltest.Column{Name: "name", TypeName: "VARCHAR"},
+				sqltest.Column{Name: "applied_at", TypeName: "TIMESTAMPTZ"})
+			for _, v := range c.applied {
+				rows.AddRow(v, "x", applied)
+			}
+			fake.ExpectQuery(`select version, name, applied_at from "schema_migrations"`).WillReturnRows(rows)
+		}
+
+		status, err := NewMigrator(conn, fsys).Status()
+		conn.CloseConnection()
+		if err != nil {
+			t.Fatalf("Status() error %v", err)
+		}
+		got := ""
+		for _, st := range status {
+			state := fmt.Sprint(st.Applied)
+			if st.Missing {
+				state = "missing"
+			}
+			got += fmt.Sprintf(" %d:%s", st.Version, state)
+		}
+		if got = strings.TrimSpace(got); got != c.want {
+			t.Errorf("Status() == %q, want %q", got, c.want)
+		}
+		if err := fake.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		for _, call := range fake.Calls() {
+			if call.Kind != "query" {
+				t.Errorf("Status() made a %s call, want queries only", call.Kind)
+			}
+		}
+	}
+}
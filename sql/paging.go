@@ -0,0 +1,247 @@
+package sql
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor : the cursor token given to QueryKeyset cannot be decoded
+var ErrInvalidCursor = errors.New("sql: invalid page cursor")
+
+// Page : one page of a query result
+type Page struct {
+	*DataTable
+	PageNo     int    // [1,n] for QueryPage, 0 for QueryKeyset
+	PageSize   int    // requested rows per page
+	TotalCount int64  // rows of the whole query
+	HasNext    bool   // there are rows after this page
+	NextCursor string // QueryKeyset token for the next page, empty on the last page
+}
+
+// PageCount : number of pages of the whole query
+func (p *Page) PageCount() int {
+	if p.PageSize <= 0 {
+		return 0
+	}
+	return int((p.TotalCount + int64(p.PageSize) - 1) / int64(p.PageSize))
+}
+
+// QueryPage : offset pagination, pageNo = [1,n]
+// QueryString must not have its own ORDER BY, orderBy is e.g. "create_time DESC, id"
+// rendered as OFFSET/FETCH on mssql and LIMIT/OFFSET on postgres
+func (bc *baseConnector) QueryPage(QueryString, orderBy string, pageNo, pageSize int, args ...interface{}) (*Page, error) {
+	if pageNo < 1 || pageSize < 1 {
+		return nil, fmt.Errorf("query page: invalid page %d size %d", pageNo, pageSize)
+	}
+	if strings.TrimSpace(orderBy) == "" {
+		return nil, errors.New("query page: orderBy is required for a stable order")
+	}
+	total, err := bc.countRows(QueryString, args)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := (pageNo - 1) * pageSize
+	pageQuery := fmt.Sprintf("SELECT * FROM (%s) AS page_src ORDER BY %s", QueryString, orderBy)
+	if bc.dialect == DialectMssql {
+		pageQuery += fmt.Sprintf(" OFFSET %d ROWS FETCH NEXT %d ROWS ONLY", offset, pageSize)
+	} else {
+		pageQuery += fmt.Sprintf(" LIMIT %d OFFSET %d", pageSize, offset)
+	}
+	table, err := bc.queryTable(pageQuery, args)
+	if err != nil {
+		return nil, err
+	}
+	return &Page{
+		DataTable:  table,
+		PageNo:     pageNo,
+		PageSize:   pageSize,
+		TotalCount: total,
+		HasNext:    int64(offset+table.RowCount()) < total,
+	}, nil
+}
+
+// QueryKeyset : keyset (seek) pagination, cursor is "" for the first page then Page.NextCursor
+// keyColumns are result columns with optional direction e.g. []string{"create_time DESC", "id DESC"},
+// together they must be unique and not null. QueryString must not have its own ORDER BY
+func (bc *baseConnector) QueryKeyset(QueryString string, keyColumns []string, cursor string, pageSize int, args ...interface{}) (*Page, error) {
+	if pageSize < 1 || len(keyColumns) == 0 {
+		return nil, fmt.Errorf("query keyset: invalid size %d or no key columns", pageSize)
+	}
+	keys := make([]string, len(keyColumns))
+	desc := make([]bool, len(keyColumns))
+	order := make([]string, len(keyColumns))
+	for i, k := range keyColumns {
+		fields := strings.Fields(k)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("query keyset: invalid key column %q", k)
+		}
+		keys[i] = fields[0]
+		desc[i] = len(fields) == 2 && strings.EqualFold(fields[1], "DESC")
+		order[i] = bc.dialect.QuoteIdent(keys[i])
+		if desc[i] {
+			order[i] += " DESC"
+		}
+	}
+
+	total, err := bc.countRows(QueryString, args)
+	if err != nil {
+		return nil, err
+	}
+
+	allArgs := append([]interface{}{}, args...)
+	where := ""
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil || len(after) != len(keys) {
+			return nil, ErrInvalidCursor
+		}
+		// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+		var or []string
+		for i := range keys {
+			var and []string
+			for j := 0; j <= i; j++ {
+				op := "="
+				if j == i {
+					op = ">"
+					if desc[j] {
+						op = "<"
+					}
+				}
+				allArgs = append(allArgs, after[j])
+				and = append(and, fmt.Sprintf("%s %s %s", bc.dialect.QuoteIdent(keys[j]), op, bc.dialect.Placeholder(len(allArgs))))
+			}
+			or = append(or, "("+strings.Join(and, " AND ")+")")
+		}
+		where = " WHERE " + strings.Join(or, " OR ")
+	}
+
+	var pageQuery string
+	if bc.dialect == DialectMssql {
+		pageQuery = fmt.Sprintf("SELECT TOP (%d) * FROM (%s) AS page_src%s ORDER BY %s",
+			pageSize+1, QueryString, where, strings.Join(order, ", "))
+	} else {
+		pageQuery = fmt.Sprintf("SELECT * FROM (%s) AS page_src%s ORDER BY %s LIMIT %d",
+			QueryString, where, strings.Join(order, ", "), pageSize+1)
+	}
+	table, err := bc.queryTable(pageQuery, allArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{DataTable: table, PageSize: pageSize, TotalCount: total}
+	if table.RowCount() > pageSize {
+		table.Rows = table.Rows[:pageSize]
+		page.HasNext = true
+		last := table.Rows[pageSize-1]
+		values := make([]interface{}, len(keys))
+		for i, k := range keys {
+			index := table.ColumnIndex(k)
+			if index < 0 {
+				return nil, fmt.Errorf("query keyset: key column %q is not in the result", k)
+			}
+			values[i] = last[index]
+		}
+		page.NextCursor, err = encodeCursor(values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func (bc *baseConnector) countRows(QueryString string, args []interface{}) (int64, error) {
+	table, err := bc.queryTable(fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS page_src", QueryString), args)
+	if err != nil {
+		return 0, err
+	}
+	if table.RowCount() != 1 {
+		return 0, errors.New("count: no result")
+	}
+	// the reader maps the count column to int32, int64 or string depending on the driver
+	return strconv.ParseInt(fmt.Sprint(table.Rows[0][0]), 10, 64)
+}
+
+func (bc *baseConnector) queryTable(QueryString string, args []interface{}) (*DataTable, error) {
+	reader, err := bc.query(QueryString, args...)
+	if err != nil {
+		return nil, err
+	}
+	return LoadDataTable(reader)
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+// cursor token: base64 of a JSON array of typed values, so the key types survive the round trip
+
+type cursorValue struct {
+	T string `json:"t"`
+	V string `json:"v,omitempty"`
+}
+
+func encodeCursor(values []interface{}) (string, error) {
+	list := make([]cursorValue, len(values))
+	for i, v := range values {
+		switch x := v.(type) {
+		case nil:
+			list[i] = cursorValue{T: "n"}
+		case string:
+			list[i] = cursorValue{"s", x}
+		case int32:
+			list[i] = cursorValue{"i", strconv.FormatInt(int64(x), 10)}
+		case int64:
+			list[i] = cursorValue{"i", strconv.FormatInt(x, 10)}
+		case float64:
+			list[i] = cursorValue{"f", strconv.FormatFloat(x, 'g', -1, 64)}
+		case bool:
+			list[i] = cursorValue{"b", strconv.FormatBool(x)}
+		case time.Time:
+			list[i] = cursorValue{"t", x.Format(time.RFC3339Nano)}
+		default:
+			return "", fmt.Errorf("cursor: unsupported key type %T", v)
+		}
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(token string) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var list []cursorValue
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	output := make([]interface{}, len(list))
+	for i, cv := range list {
+		switch cv.T {
+		case "n":
+			output[i] = nil
+		case "s":
+			output[i] = cv.V
+		case "i":
+			output[i], err = strconv.ParseInt(cv.V, 10, 64)
+		case "f":
+			output[i], err = strconv.ParseFloat(cv.V, 64)
+		case "b":
+			output[i], err = strconv.ParseBool(cv.V)
+		case "t":
+			output[i], err = time.Parse(time.RFC3339Nano, cv.V)
+		default:
+			err = ErrInvalidCursor
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
@@ -0,0 +1,52 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	values := []interface{}{"U1", int64(42), 1.5, true, time.Date(2021, 6, 1, 8, 30, 0, 0, time.UTC), nil}
+	token, err := encodeCursor(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeCursor(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("decodeCursor(encodeCursor(%v)) == %v", values, got)
+	}
+	if _, err := decodeCursor("not a cursor"); err == nil {
+		t.Errorf("decodeCursor(garbage) expect error")
+	}
+}
+
+func TestDataTableReader(t *testing.T) {
+	dt := &DataTable{
+		Columns:    []string{"id", "name", "amount"},
+		TypeNames:  []string{"BIGINT", "NVARCHAR", "DECIMAL"},
+		TypeNames2: []string{"BIGINT", "NVARCHAR(50)", "DECIMAL(10,2)"},
+		Rows: [][]interface{}{
+			{int64(1), "a", 1.25},
+			{int64(2), nil, 2.5},
+		},
+	}
+
+	for pass := 0; pass < 2; pass++ {
+		reader := dt.Reader()
+		var got [][]interface{}
+		for reader.Read() {
+			got = append(got, reader.GetValues())
+		}
+		reader.Close()
+		if !reflect.DeepEqual(got, dt.Rows) {
+			t.Errorf("pass %d: rows == %v, want %v", pass, got, dt.Rows)
+		}
+		if reader.GetName(1) != "name" || reader.GetDataTypeName2(2) != "DECIMAL(10,2)" || !reader.IsNull(1) {
+			t.Errorf("pass %d: column info mismatch", pass)
+		}
+	}
+}
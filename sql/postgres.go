@@ -3,14 +3,13 @@ package sql
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"net/url"
 
 	_ "github.com/lib/pq"
 )
 
 type PostgresConnector struct {
-	db            *sql.DB
+	baseConnector
 	ServerName    string
 	ServerPort    string
 	Username      string
@@ -21,6 +20,7 @@ type PostgresConnector struct {
 
 func NewPostgresConnector(host, port, user, password, dbname string) *PostgresConnector {
 	conn := &PostgresConnector{
+		baseConnector: baseConnector{dialect: DialectPostgres},
 		ServerName:    host,
 		ServerPort:    port,
 		Username:      user,
@@ -33,6 +33,7 @@ func NewPostgresConnector(host, port, user, password, dbname string) *PostgresCo
 
 func NewPostgresConnector2(connStr string) *PostgresConnector {
 	conn := &PostgresConnector{
+		baseConnector: baseConnector{dialect: DialectPostgres},
 		ServerName:    "",
 		ServerPort:    "",
 		Username:      "",
@@ -43,7 +44,11 @@ func NewPostgresConnector2(connStr string) *PostgresConnector {
 	return conn
 }
 
-func (conn *PostgresConnector) OpenConnection() error {
+// connectionString : ConnectionStr if given, otherwise built from the connection fields
+func (conn *PostgresConnector) connectionString() string {
+	if conn.ConnectionStr != "" {
+		return conn.ConnectionStr
+	}
 	query := url.Values{}
 	query.Add("database", conn.Database)
 	var u *url.URL
@@ -53,12 +58,14 @@ func (conn *PostgresConnector) OpenConnection() error {
 		Host:     fmt.Sprintf("%s", conn.ServerName),
 		RawQuery: query.Encode(),
 	}
+	return u.String()
+}
 
-	if conn.ConnectionStr == "" {
-		conn.ConnectionStr = u.String()
-	}
+func (conn *PostgresConnector) OpenConnection() error {
+	conn.dialect = DialectPostgres
+	conn.ConnectionStr = conn.connectionString()
 	var err error
-	conn.db, err = sql.Open("postgres", conn.ConnectionStr)
+	conn.db, err = sql.Open(conn.driverName("postgres"), conn.ConnectionStr)
 	if err != nil {
 		return err
 	}
@@ -66,32 +73,10 @@ func (conn *PostgresConnector) OpenConnection() error {
 	// check if connected
 	err = conn.db.Ping()
 	if err != nil {
-		fmt.Printf("postgres: cannot connect\n")
+		conn.logger().Log(LevelError, "postgres: cannot connect", "dsn", MaskDSN(conn.ConnectionStr), "error", err)
 		return err
 	}
-	fmt.Printf("postgres: connected\n")
+	conn.logger().Log(LevelInfo, "postgres: connected", "dsn", MaskDSN(conn.ConnectionStr))
 
 	return nil
 }
-
-func (conn *PostgresConnector) CloseConnection() {
-	conn.db.Close()
-}
-
-func (conn *PostgresConnector) Query(QueryString string, args ...interface{}) *DataReader {
-	rows, err := conn.db.Query(QueryString, args...)
-	if err != nil {
-		// log.Fatal(err)
-		log.Printf("%s\n", err)
-		return nil
-	}
-	return CreateDataReader(rows)
-}
-
-func (conn *PostgresConnector) NonQuery(QueryString string, args ...interface{}) error {
-	_, err := conn.db.Query(QueryString, args...)
-	if err != nil {
-		return err
-	}
-	return nil
-}
@@ -0,0 +1,616 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Builder : statement that renders to parameterized SQL for a dialect
+//
+//	q, args, err := sql.Select("user_id", "display_name").
+//		From("register_user").
+//		Where(sql.Eq("bot_id", botID), sql.Like("display_name", "%"+name+"%")).
+//		OrderBy("display_name").
+//		Limit(20).
+//		ToSQL(conn.Dialect())
+//	reader := conn.Query(q, args...)
+type Builder interface {
+	ToSQL(d Dialect) (string, []interface{}, error)
+}
+
+// ErrNoWhere : Update or Delete without condition, call AllRows() to really touch every row
+var ErrNoWhere = errors.New("sql: update/delete without where, use AllRows()")
+
+// sqlWriter : collect SQL text and arguments, numbering placeholders for the dialect
+type sqlWriter struct {
+	d    Dialect
+	sb   strings.Builder
+	args []interface{}
+}
+
+func (w *sqlWriter) write(s string) {
+	w.sb.WriteString(s)
+}
+
+func (w *sqlWriter) param(v interface{}) {
+	w.args = append(w.args, v)
+	w.sb.WriteString(w.d.Placeholder(len(w.args)))
+}
+
+// raw : write an expression, ? outside string literals are replaced by placeholders for args
+func (w *sqlWriter) raw(expr string, args []interface{}) error {
+	n := 0
+	inString := false
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		switch {
+		case ch == '\'':
+			inString = !inString
+			w.sb.WriteByte(ch)
+		case ch == '?' && !inString:
+			if n >= len(args) {
+				return fmt.Errorf("sql: not enough arguments for %q", expr)
+			}
+			w.param(args[n])
+			n++
+		default:
+			w.sb.WriteByte(ch)
+		}
+	}
+	if n != len(args) {
+		return fmt.Errorf("sql: %d arguments for %d placeholders in %q", len(args), n, expr)
+	}
+	return nil
+}
+
+var reIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*(\.\*)?$`)
+
+// name : quote plain (optionally dotted) identifiers, anything else e.g. "count(*)", "users u" is written as is
+func (w *sqlWriter) name(s string) string {
+	if !reIdentifier.MatchString(s) {
+		return s
+	}
+	if strings.HasSuffix(s, ".*") {
+		return w.d.QuoteIdent(strings.TrimSuffix(s, ".*")) + ".*"
+	}
+	return w.d.QuoteIdent(s)
+}
+
+func (w *sqlWriter) names(list []string) string {
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = w.name(s)
+	}
+	return strings.Join(output, ", ")
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+// Cond : condition for Where, Having and joins
+type Cond interface {
+	writeTo(w *sqlWriter) error
+}
+
+type compareCond struct {
+	column string
+	op     string
+	value  interface{}
+}
+
+func (c compareCond) writeTo(w *sqlWriter) error {
+	w.write(w.name(c.column) + " " + c.op + " ")
+	w.param(c.value)
+	return nil
+}
+
+// Eq : column = value, a nil value gives column IS NULL
+func Eq(column string, value interface{}) Cond {
+	if value == nil {
+		return IsNull(column)
+	}
+	return compareCond{column, "=", value}
+}
+
+// NotEq : column <> value, a nil value gives column IS NOT NULL
+func NotEq(column string, value interface{}) Cond {
+	if value == nil {
+		return IsNotNull(column)
+	}
+	return compareCond{column, "<>", value}
+}
+
+func Gt(column string, value interface{}) Cond  { return compareCond{column, ">", value} }
+func Gte(column string, value interface{}) Cond { return compareCond{column, ">=", value} }
+func Lt(column string, value interface{}) Cond  { return compareCond{column, "<", value} }
+func Lte(column string, value interface{}) Cond { return compareCond{column, "<=", value} }
+
+// Like : column LIKE pattern, the caller adds the % wildcards
+func Like(column string, pattern string) Cond { return compareCond{column, "LIKE", pattern} }
+
+type columnCond struct {
+	left, right string
+}
+
+func (c columnCond) writeTo(w *sqlWriter) error {
+	w.write(w.name(c.left) + " = " + w.name(c.right))
+	return nil
+}
+
+// EqColumn : left = right for two columns, mostly for joins e.g. EqColumn("u.user_id", "e.source_userid")
+func EqColumn(left, right string) Cond { return columnCond{left, right} }
+
+type nullCond struct {
+	column string
+	not    bool
+}
+
+func (c nullCond) writeTo(w *sqlWriter) error {
+	if c.not {
+		w.write(w.name(c.column) + " IS NOT NULL")
+	} else {
+		w.write(w.name(c.column) + " IS NULL")
+	}
+	return nil
+}
+
+func IsNull(column string) Cond    { return nullCond{column, false} }
+func IsNotNull(column string) Cond { return nullCond{column, true} }
+
+type inCond struct {
+	column string
+	values []interface{}
+	not    bool
+}
+
+func (c inCond) writeTo(w *sqlWriter) error {
+	if len(c.values) == 0 {
+		if c.not {
+			w.write("1 = 1")
+		} else {
+			w.write("1 = 0")
+		}
+		return nil
+	}
+	w.write(w.name(c.column))
+	if c.not {
+		w.write(" NOT")
+	}
+	w.write(" IN (")
+	for i, v := range c.values {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.param(v)
+	}
+	w.write(")")
+	return nil
+}
+
+// In : column IN (values...), no values gives a condition that is always false
+func In(column string, values ...interface{}) Cond { return inCond{column, values, false} }
+
+// NotIn : column NOT IN (values...), no values gives a condition that is always true
+func NotIn(column string, values ...interface{}) Cond { return inCond{column, values, true} }
+
+type betweenCond struct {
+	column    string
+	low, high interface{}
+}
+
+func (c betweenCond) writeTo(w *sqlWriter) error {
+	w.write(w.name(c.column) + " BETWEEN ")
+	w.param(c.low)
+	w.write(" AND ")
+	w.param(c.high)
+	return nil
+}
+
+func Between(column string, low, high interface{}) Cond { return betweenCond{column, low, high} }
+
+type listCond struct {
+	op    string
+	conds []Cond
+}
+
+func (c listCond) writeTo(w *sqlWriter) error {
+	if len(c.conds) == 1 {
+		return c.conds[0].writeTo(w)
+	}
+	w.write("(")
+	for i, cond := range c.conds {
+		if i > 0 {
+			w.write(" " + c.op + " ")
+		}
+		if err := cond.writeTo(w); err != nil {
+			return err
+		}
+	}
+	w.write(")")
+	return nil
+}
+
+// And : all conditions, nil conditions are skipped so optional filters can be passed directly
+func And(conds ...Cond) Cond { return newListCond("AND", conds) }
+
+// Or : any of the conditions, nil conditions are skipped
+func Or(conds ...Cond) Cond { return newListCond("OR", conds) }
+
+func newListCond(op string, conds []Cond) Cond {
+	list := make([]Cond, 0, len(conds))
+	for _, c := range conds {
+		if lc, ok := c.(listCond); ok && lc.op == op {
+			list = append(list, lc.conds...) // (a AND b) AND c => a AND b AND c
+		} else if c != nil {
+			list = append(list, c)
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	return listCond{op, list}
+}
+
+type notCond struct {
+	cond Cond
+}
+
+func (c notCond) writeTo(w *sqlWriter) error {
+	w.write("NOT (")
+	if err := c.cond.writeTo(w); err != nil {
+		return err
+	}
+	w.write(")")
+	return nil
+}
+
+// Not : NOT (c), a nil condition gives nil so it is skipped like the nil conditions of And and Or
+func Not(c Cond) Cond {
+	if c == nil {
+		return nil
+	}
+	return notCond{c}
+}
+
+type exprCond struct {
+	expr string
+	args []interface{}
+}
+
+func (c exprCond) writeTo(w *sqlWriter) error {
+	return w.raw(c.expr, c.args)
+}
+
+// Expr : raw SQL condition, use ? for each argument e.g. Expr("create_time >= dateadd(day, ?, getdate())", -7)
+func Expr(expr string, args ...interface{}) Cond { return exprCond{expr, args} }
+
+// writeWhere : " WHERE cond", nothing if cond is nil
+func writeWhere(w *sqlWriter, keyword string, cond Cond) error {
+	if cond == nil {
+		return nil
+	}
+	w.write(" " + keyword + " ")
+	return cond.writeTo(w)
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+type join struct {
+	kind  string
+	table string
+	on    Cond
+}
+
+// SelectBuilder : SELECT statement, see Select
+type SelectBuilder struct {
+	columns  []string
+	distinct bool
+	from     string
+	joins    []join
+	where    Cond
+	groupBy  []string
+	having   Cond
+	orderBy  []string
+	limit    int
+	offset   int
+}
+
+// Select : start a SELECT, no columns means *
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns, limit: -1}
+}
+
+func (b *SelectBuilder) Distinct() *SelectBuilder {
+	b.distinct = true
+	return b
+}
+
+// From : table name, may carry an alias e.g. "register_user u"
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.from = table
+	return b
+}
+
+func (b *SelectBuilder) Join(table string, on Cond) *SelectBuilder {
+	b.joins = append(b.joins, join{"JOIN", table, on})
+	return b
+}
+
+func (b *SelectBuilder) LeftJoin(table string, on Cond) *SelectBuilder {
+	b.joins = append(b.joins, join{"LEFT JOIN", table, on})
+	return b
+}
+
+// Where : add conditions, every call is combined with AND, nil conditions are skipped
+func (b *SelectBuilder) Where(conds ...Cond) *SelectBuilder {
+	b.where = And(append([]Cond{b.where}, conds...)...)
+	return b
+}
+
+func (b *SelectBuilder) GroupBy(columns ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, columns...)
+	return b
+}
+
+func (b *SelectBuilder) Having(conds ...Cond) *SelectBuilder {
+	b.having = And(append([]Cond{b.having}, conds...)...)
+	return b
+}
+
+// OrderBy : columns with optional direction e.g. OrderBy("create_time DESC", "id")
+func (b *SelectBuilder) OrderBy(columns ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, columns...)
+	return b
+}
+
+// Limit : maximum number of rows, rendered as LIMIT, TOP or FETCH NEXT
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset : rows to skip, mssql needs an ORDER BY and uses ORDER BY (SELECT NULL) if none is given
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+func (b *SelectBuilder) ToSQL(d Dialect) (string, []interface{}, error) {
+	if b.from == "" {
+		return "", nil, errors.New("sql: select without from")
+	}
+	w := &sqlWriter{d: d}
+	w.write("SELECT ")
+	if b.distinct {
+		w.write("DISTINCT ")
+	}
+	mssqlPaging := d == DialectMssql && b.offset > 0
+	if d == DialectMssql && b.limit >= 0 && !mssqlPaging {
+		w.write(fmt.Sprintf("TOP (%d) ", b.limit))
+	}
+	if len(b.columns) == 0 {
+		w.write("*")
+	} else {
+		w.write(w.names(b.columns))
+	}
+	w.write(" FROM " + w.name(b.from))
+	for _, j := range b.joins {
+		w.write(" " + j.kind + " " + w.name(j.table))
+		if err := writeWhere(w, "ON", j.on); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := writeWhere(w, "WHERE", b.where); err != nil {
+		return "", nil, err
+	}
+	if len(b.groupBy) > 0 {
+		w.write(" GROUP BY " + w.names(b.groupBy))
+	}
+	if err := writeWhere(w, "HAVING", b.having); err != nil {
+		return "", nil, err
+	}
+	if len(b.orderBy) > 0 {
+		w.write(" ORDER BY " + orderList(w, b.orderBy))
+	} else if mssqlPaging {
+		w.write(" ORDER BY (SELECT NULL)")
+	}
+
+	if mssqlPaging {
+		w.write(fmt.Sprintf(" OFFSET %d ROWS", b.offset))
+		if b.limit >= 0 {
+			w.write(fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", b.limit))
+		}
+	} else if d != DialectMssql {
+		if b.limit >= 0 {
+			w.write(fmt.Sprintf(" LIMIT %d", b.limit))
+		}
+		if b.offset > 0 {
+			w.write(fmt.Sprintf(" OFFSET %d", b.offset))
+		}
+	}
+	return w.sb.String(), w.args, nil
+}
+
+var reOrderItem = regexp.MustCompile(`(?i)^\s*(\S+?)(\s+(?:ASC|DESC))?\s*$`)
+
+// orderList : quote the column of "column [ASC|DESC]" items
+func orderList(w *sqlWriter, items []string) string {
+	output := make([]string, len(items))
+	for i, item := range items {
+		if m := reOrderItem.FindStringSubmatch(item); m != nil {
+			output[i] = w.name(m[1]) + strings.ToUpper(m[2])
+		} else {
+			output[i] = item
+		}
+	}
+	return strings.Join(output, ", ")
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+// InsertBuilder : INSERT statement, see InsertInto
+type InsertBuilder struct {
+	table   string
+	columns []string
+	rows    [][]interface{}
+}
+
+func InsertInto(table string) *InsertBuilder {
+	return &InsertBuilder{table: table}
+}
+
+func (b *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	b.columns = columns
+	return b
+}
+
+// Values : add one row, one value per column
+func (b *InsertBuilder) Values(values ...interface{}) *InsertBuilder {
+	b.rows = append(b.rows, values)
+	return b
+}
+
+// SetMap : columns and a single row from a map, columns are written in name order
+func (b *InsertBuilder) SetMap(values map[string]interface{}) *InsertBuilder {
+	b.columns = sortedKeys(values)
+	row := make([]interface{}, len(b.columns))
+	for i, c := range b.columns {
+		row[i] = values[c]
+	}
+	b.rows = [][]interface{}{row}
+	return b
+}
+
+func (b *InsertBuilder) ToSQL(d Dialect) (string, []interface{}, error) {
+	if len(b.columns) == 0 || len(b.rows) == 0 {
+		return "", nil, fmt.Errorf("sql: insert into %s without columns or values", b.table)
+	}
+	w := &sqlWriter{d: d}
+	w.write("INSERT INTO " + w.name(b.table) + " (" + w.names(b.columns) + ") VALUES ")
+	for i, row := range b.rows {
+		if len(row) != len(b.columns) {
+			return "", nil, fmt.Errorf("sql: insert into %s row %d has %d values, want %d", b.table, i+1, len(row), len(b.columns))
+		}
+		if i > 0 {
+			w.write(", ")
+		}
+		w.write("(")
+		for j, v := range row {
+			if j > 0 {
+				w.write(", ")
+			}
+			w.param(v)
+		}
+		w.write(")")
+	}
+	return w.sb.String(), w.args, nil
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+// UpdateBuilder : UPDATE statement, see Update
+type UpdateBuilder struct {
+	table   string
+	columns []string
+	values  []interface{}
+	where   Cond
+	all     bool
+}
+
+func Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{table: table}
+}
+
+func (b *UpdateBuilder) Set(column string, value interface{}) *UpdateBuilder {
+	b.columns = append(b.columns, column)
+	b.values = append(b.values, value)
+	return b
+}
+
+// SetMap : set several columns, written in name order
+func (b *UpdateBuilder) SetMap(values map[string]interface{}) *UpdateBuilder {
+	for _, c := range sortedKeys(values) {
+		b.Set(c, values[c])
+	}
+	return b
+}
+
+func (b *UpdateBuilder) Where(conds ...Cond) *UpdateBuilder {
+	b.where = And(append([]Cond{b.where}, conds...)...)
+	return b
+}
+
+// AllRows : allow an update without where
+func (b *UpdateBuilder) AllRows() *UpdateBuilder {
+	b.all = true
+	return b
+}
+
+func (b *UpdateBuilder) ToSQL(d Dialect) (string, []interface{}, error) {
+	if len(b.columns) == 0 {
+		return "", nil, fmt.Errorf("sql: update %s without columns", b.table)
+	}
+	if b.where == nil && !b.all {
+		return "", nil, ErrNoWhere
+	}
+	w := &sqlWriter{d: d}
+	w.write("UPDATE " + w.name(b.table) + " SET ")
+	for i, c := range b.columns {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.write(w.name(c) + " = ")
+		w.param(b.values[i])
+	}
+	if err := writeWhere(w, "WHERE", b.where); err != nil {
+		return "", nil, err
+	}
+	return w.sb.String(), w.args, nil
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+// DeleteBuilder : DELETE statement, see DeleteFrom
+type DeleteBuilder struct {
+	table string
+	where Cond
+	all   bool
+}
+
+func DeleteFrom(table string) *DeleteBuilder {
+	return &DeleteBuilder{table: table}
+}
+
+func (b *DeleteBuilder) Where(conds ...Cond) *DeleteBuilder {
+	b.where = And(append([]Cond{b.where}, conds...)...)
+	return b
+}
+
+// AllRows : allow a delete without where
+func (b *DeleteBuilder) AllRows() *DeleteBuilder {
+	b.all = true
+	return b
+}
+
+func (b *DeleteBuilder) ToSQL(d Dialect) (string, []interface{}, error) {
+	if b.where == nil && !b.all {
+		return "", nil, ErrNoWhere
+	}
+	w := &sqlWriter{d: d}
+	w.write("DELETE FROM " + w.name(b.table))
+	if err := writeWhere(w, "WHERE", b.where); err != nil {
+		return "", nil, err
+	}
+	return w.sb.String(), w.args, nil
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,99 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectBuilder(t *testing.T) {
+	b := Select("u.user_id", "u.display_name", "count(*) AS events").
+		From("register_user u").
+		LeftJoin("log_event e", And(EqColumn("e.source_userid", "u.user_id"), EqColumn("e.bot_id", "u.bot_id"))).
+		Where(Eq("u.bot_id", 3), nil, Or(Like("u.display_name", "%a%"), IsNull("u.display_name"))).
+		Where(In("e.event_type", "message", "follow")).
+		GroupBy("u.user_id", "u.display_name").
+		OrderBy("events desc", "u.user_id").
+		Limit(20)
+
+	cases := []struct {
+		dialect Dialect
+		offset  int
+		want    string
+	}{
+		{DialectPostgres, 0, `SELECT "u"."user_id", "u"."display_name", count(*) AS events FROM register_user u ` +
+			`LEFT JOIN log_event e ON ("e"."source_userid" = "u"."user_id" AND "e"."bot_id" = "u"."bot_id") ` +
+			`WHERE ("u"."bot_id" = $1 AND ("u"."display_name" LIKE $2 OR "u"."display_name" IS NULL) AND "e"."event_type" IN ($3, $4)) ` +
+			`GROUP BY "u"."user_id", "u"."display_name" ORDER BY "events" DESC, "u"."user_id" LIMIT 20`},
+		{DialectPostgres, 40, `SELECT "u"."user_id", "u"."display_name", count(*) AS events FROM register_user u ` +
+			`LEFT JOIN log_event e ON ("e"."source_userid" = "u"."user_id" AND "e"."bot_id" = "u"."bot_id") ` +
+			`WHERE ("u"."bot_id" = $1 AND ("u"."display_name" LIKE $2 OR "u"."display_name" IS NULL) AND "e"."event_type" IN ($3, $4)) ` +
+			`GROUP BY "u"."user_id", "u"."display_name" ORDER BY "events" DESC, "u"."user_id" LIMIT 20 OFFSET 40`},
+		{DialectMssql, 0, `SELECT TOP (20) [u].[user_id], [u].[display_name], count(*) AS events FROM register_user u ` +
+			`LEFT JOIN log_event e ON ([e].[source_userid] = [u].[user_id] AND [e].[bot_id] = [u].[bot_id]) ` +
+			`WHERE ([u].[bot_id] = @p1 AND ([u].[display_name] LIKE @p2 OR [u].[display_name] IS NULL) AND [e].[event_type] IN (@p3, @p4)) ` +
+			`GROUP BY [u].[user_id], [u].[display_name] ORDER BY [events] DESC, [u].[user_id]`},
+		{DialectMssql, 40, `SELECT [u].[user_id], [u].[display_name], count(*) AS events FROM register_user u ` +
+			`LEFT JOIN log_event e ON ([e].[source_userid] = [u].[user_id] AND [e].[bot_id] = [u].[bot_id]) ` +
+			`WHERE ([u].[bot_id] = @p1 AND ([u].[display_name] LIKE @p2 OR [u].[display_name] IS NULL) AND [e].[event_type] IN (@p3, @p4)) ` +
+			`GROUP BY [u].[user_id], [u].[display_name] ORDER BY [events] DESC, [u].[user_id] OFFSET 40 ROWS FETCH NEXT 20 ROWS ONLY`},
+	}
+
+	for _, c := range cases {
+		got, args, err := b.Offset(c.offset).ToSQL(c.dialect)
+		if err != nil {
+			t.Fatalf("ToSQL(%s) error %v", c.dialect, err)
+		}
+		if got != c.want {
+			t.Errorf("ToSQL(%s, offset %d) ==\n%s\nwant\n%s", c.dialect, c.offset, got, c.want)
+		}
+		if want := []interface{}{3, "%a%", "message", "follow"}; !reflect.DeepEqual(args, want) {
+			t.Errorf("ToSQL(%s) args == %v, want %v", c.dialect, args, want)
+		}
+	}
+
+	got, _, _ := Select().From("log_event").Offset(10).ToSQL(DialectMssql)
+	if want := `SELECT * FROM [log_event] ORDER BY (SELECT NULL) OFFSET 10 ROWS`; got != want {
+		t.Errorf("ToSQL(mssql, offset only) == %q, want %q", got, want)
+	}
+}
+
+func TestModifyBuilders(t *testing.T) {
+	cases := []struct {
+		b    Builder
+		want string
+		args []interface{}
+	}{
+		{InsertInto("log_event").Columns("bot_id", "event_type").Values(1, "follow").Values(2, "message"),
+			`INSERT INTO [log_event] ([bot_id], [event_type]) VALUES (@p1, @p2), (@p3, @p4)`,
+			[]interface{}{1, "follow", 2, "message"}},
+		{Update("register_user").Set("intent_stage", "menu").Where(Eq("user_id", "U1"), Expr("bot_id IN (?, ?)", 1, 2)),
+			`UPDATE [register_user] SET [intent_stage] = @p1 WHERE ([user_id] = @p2 AND bot_id IN (@p3, @p4))`,
+			[]interface{}{"menu", "U1", 1, 2}},
+		{DeleteFrom("log_event").Where(Lt("create_time", "2021-01-01"), Not(Eq("event_type", nil))),
+			`DELETE FROM [log_event] WHERE ([create_time] < @p1 AND NOT ([event_type] IS NULL))`,
+			[]interface{}{"2021-01-01"}},
+		{DeleteFrom("log_event").Where(Eq("bot_id", 1), Not(nil), Not(And())),
+			`DELETE FROM [log_event] WHERE [bot_id] = @p1`,
+			[]interface{}{1}},
+		{DeleteFrom("log_event").AllRows(), `DELETE FROM [log_event]`, nil},
+	}
+	for _, c := range cases {
+		got, args, err := c.b.ToSQL(DialectMssql)
+		if err != nil {
+			t.Fatalf("ToSQL error %v", err)
+		}
+		if got != c.want || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("ToSQL == %q %v, want %q %v", got, args, c.want, c.args)
+		}
+	}
+
+	if _, _, err := DeleteFrom("log_event").ToSQL(DialectPostgres); err != ErrNoWhere {
+		t.Errorf("DeleteFrom without where == %v, want ErrNoWhere", err)
+	}
+	if _, _, err := DeleteFrom("log_event").Where(Not(nil)).ToSQL(DialectPostgres); err != ErrNoWhere {
+		t.Errorf("DeleteFrom with Not(nil) == %v, want ErrNoWhere", err)
+	}
+	if _, _, err := Update("t").Set("a", 1).Where(Expr("a = ?")).ToSQL(DialectPostgres); err == nil {
+		t.Errorf("Expr with missing argument expect error")
+	}
+}
@@ -0,0 +1,245 @@
+package sql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaSet : one primary and several read replicas behind the DbConnector interface
+// Query goes round-robin to healthy replicas and falls back to the primary,
+// NonQuery and Begin always go to the primary
+//
+//	rs := sql.NewReplicaSet(primary, replica1, replica2)
+//	rs.StickyAfterWrite = 2 * time.Second
+//	rs.OpenConnection()
+//	defer rs.CloseConnection()
+type ReplicaSet struct {
+	Primary  DbConnector
+	Replicas []DbConnector
+
+	// HealthCheckInterval pings every replica this often, 0 disables the background check
+	HealthCheckInterval time.Duration
+	// StickyAfterWrite sends reads to the primary for this long after a write, so callers read their writes
+	StickyAfterWrite time.Duration
+	// Logger receives health changes, nil means the package default logger
+	Logger Logger
+
+	mu        sync.RWMutex
+	healthy   []bool
+	next      uint32
+	lastWrite time.Time
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func NewReplicaSet(primary DbConnector, replicas ...DbConnector) *ReplicaSet {
+	return &ReplicaSet{
+		Primary:             primary,
+		Replicas:            replicas,
+		HealthCheckInterval: 10 * time.Second,
+	}
+}
+
+func (rs *ReplicaSet) logger() Logger {
+	if rs.Logger != nil {
+		return rs.Logger
+	}
+	return defaultLogger
+}
+
+// OpenConnection : open the primary and every replica, a replica that fails is marked unhealthy
+// and retried by the health check, only a primary failure is returned
+func (rs *ReplicaSet) OpenConnection() error {
+	if err := rs.Primary.OpenConnection(); err != nil {
+		return err
+	}
+	rs.mu.Lock()
+	rs.healthy = make([]bool, len(rs.Replicas))
+	rs.mu.Unlock()
+	for i, r := range rs.Replicas {
+		err := r.OpenConnection()
+		if err != nil {
+			rs.logger().Log(LevelWarn, "replica unhealthy", "replica", i, "error", err)
+		}
+		rs.setHealthy(i, err == nil, err)
+	}
+
+	if rs.HealthCheckInterval > 0 && rs.stop == nil {
+		rs.stop = make(chan struct{})
+		rs.wg.Add(1)
+		go rs.healthLoop(rs.stop)
+	}
+	return nil
+}
+
+func (rs *ReplicaSet) CloseConnection() {
+	if rs.stop != nil {
+		close(rs.stop)
+		rs.wg.Wait()
+		rs.stop = nil
+	}
+	for _, r := range rs.Replicas {
+		r.CloseConnection()
+	}
+	rs.Primary.CloseConnection()
+}
+
+// Query : run on the next healthy replica, on the primary if none is healthy, the replica connection fails
+// or a write happened within StickyAfterWrite
+// other errors of the replica, e.g. a syntax error, are returned as nil without trying the primary
+func (rs *ReplicaSet) Query(QueryString string, args ...interface{}) *DataReader {
+	reader, err := rs.query(QueryString, args...)
+	if err != nil {
+		rs.logger().Log(LevelError, "query failed", "error", err)
+		return nil
+	}
+	return reader
+}
+
+func (rs *ReplicaSet) query(QueryString string, args ...interface{}) (*DataReader, error) {
+	if !rs.sticky() {
+		if i, r := rs.pickReplica(); r != nil {
+			reader, err := queryConn(r, QueryString, args...)
+			if err == nil || !isConnError(err) {
+				return reader, err
+			}
+			rs.CheckReplica(i)
+		}
+	}
+	return queryConn(rs.Primary, QueryString, args...)
+}
+
+// QueryPrimary : read from the primary, for reads that must see the caller's own writes
+func (rs *ReplicaSet) QueryPrimary(QueryString string, args ...interface{}) *DataReader {
+	return rs.Primary.Query(QueryString, args...)
+}
+
+// NonQuery : run on the primary
+func (rs *ReplicaSet) NonQuery(QueryString string, args ...interface{}) error {
+	rs.markWrite()
+	return rs.Primary.NonQuery(QueryString, args...)
+}
+
+// Begin : start a transaction on the primary
+func (rs *ReplicaSet) Begin() (*Transaction, error) {
+	rs.markWrite()
+	return rs.Primary.Begin()
+}
+
+func (rs *ReplicaSet) Dialect() Dialect {
+	return rs.Primary.Dialect()
+}
+
+// DB : database/sql handle of the primary
+func (rs *ReplicaSet) DB() *sql.DB {
+	return rs.Primary.DB()
+}
+
+// CheckHealth : ping every replica now and update its state
+func (rs *ReplicaSet) CheckHealth() {
+	for i := range rs.Replicas {
+		rs.CheckReplica(i)
+	}
+}
+
+// CheckReplica : ping replica i = [0,n) and update its state
+func (rs *ReplicaSet) CheckReplica(i int) {
+	r := rs.Replicas[i]
+	var err error
+	if db := r.DB(); db == nil {
+		err = r.OpenConnection()
+	} else {
+		err = db.Ping()
+	}
+	rs.setHealthy(i, err == nil, err)
+}
+
+// Healthy : health state of every replica, in the order of Replicas
+func (rs *ReplicaSet) Healthy() []bool {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return append([]bool(nil), rs.healthy...)
+}
+
+func (rs *ReplicaSet) healthLoop(stop chan struct{}) {
+	defer rs.wg.Done()
+	ticker := time.NewTicker(rs.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			rs.CheckHealth()
+		}
+	}
+}
+
+func (rs *ReplicaSet) setHealthy(i int, ok bool, err error) {
+	rs.mu.Lock()
+	if len(rs.healthy) != len(rs.Replicas) {
+		rs.healthy = make([]bool, len(rs.Replicas))
+	}
+	changed := rs.healthy[i] != ok
+	rs.healthy[i] = ok
+	rs.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if ok {
+		rs.logger().Log(LevelInfo, "replica healthy", "replica", i)
+	} else {
+		rs.logger().Log(LevelWarn, "replica unhealthy", "replica", i, "error", err)
+	}
+}
+
+// pickReplica : next healthy replica in round-robin order, nil if none
+func (rs *ReplicaSet) pickReplica() (int, DbConnector) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	n := len(rs.healthy)
+	if n == 0 {
+		return -1, nil
+	}
+	start := int(atomic.AddUint32(&rs.next, 1)-1) % n
+	for k := 0; k < n; k++ {
+		i := (start + k) % n
+		if rs.healthy[i] {
+			return i, rs.Replicas[i]
+		}
+	}
+	return -1, nil
+}
+
+// isConnError : the error comes from the connection rather than the statement, so another server may succeed
+func isConnError(err error) bool {
+	var ne net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, ErrNotOpen) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &ne)
+}
+
+func (rs *ReplicaSet) markWrite() {
+	if rs.StickyAfterWrite > 0 {
+		rs.mu.Lock()
+		rs.lastWrite = time.Now()
+		rs.mu.Unlock()
+	}
+}
+
+func (rs *ReplicaSet) sticky() bool {
+	if rs.StickyAfterWrite <= 0 {
+		return false
+	}
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return !rs.lastWrite.IsZero() && time.Since(rs.lastWrite) < rs.StickyAfterWrite
+}
+
+var _ DbConnector = (*ReplicaSet)(nil)
@@ -0,0 +1,135 @@
+package sql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/churikawit/gosrc/sql/sqltest"
+)
+
+// newReplicaSet : primary and replicas on fake databases, health is only checked when the test asks
+func newReplicaSet(t *testing.T, replicas int) (*ReplicaSet, []*sqltest.DB) {
+	var conns []DbConnector
+	var fakes []*sqltest.DB
+	for i := 0; i <= replicas; i++ {
+		fake := sqltest.New()
+		conn := NewPostgresConnector2(fake.DSN())
+		conn.DriverName = sqltest.DriverName
+		conn.SetLogger(NopLogger())
+		conns = append(conns, conn)
+		fakes = append(fakes, fake)
+	}
+	rs := NewReplicaSet(conns[0], conns[1:]...)
+	rs.HealthCheckInterval = 0
+	rs.Logger = NopLogger()
+	if err := rs.OpenConnection(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rs.CloseConnection)
+	return rs, fakes
+}
+
+func serverRows(name string) *sqltest.Rows {
+	return sqltest.NewRows(sqltest.Column{Name: "server", TypeName: "VARCHAR"}).AddRow(name)
+}
+
+// readServer : run the query and return the server name it was answered by, "" if it failed
+func readServer(rs *ReplicaSet) string {
+	reader := rs.Query("select server")
+	if reader == nil {
+		return ""
+	}
+	defer reader.Close()
+	if !reader.Read() {
+		return ""
+	}
+	return reader.GetValue2(0).(string)
+}
+
+func TestReplicaSetRouting(t *testing.T) {
+	rs, fakes := newReplicaSet(t, 2)
+	for i, name := range []string{"primary", "replica1", "replica2"} {
+		fakes[i].ExpectQuery("select server").WillReturnRows(serverRows(name)).AnyTimes()
+	}
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, readServer(rs))
+	}
+	if want := []string{"replica1", "replica2", "replica1", "replica2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reads == %v, want %v", got, want)
+	}
+	if got := rs.QueryPrimary("select server"); got == nil {
+		t.Error("QueryPrimary == nil")
+	} else {
+		got.Close()
+	}
+}
+
+func TestReplicaSetStickyAfterWrite(t *testing.T) {
+	rs, fakes := newReplicaSet(t, 1)
+	rs.StickyAfterWrite = 50 * time.Millisecond
+	fakes[0].ExpectExec("update orders set paid = true").WillReturnResult(0, 1)
+	fakes[0].ExpectQuery("select server").WillReturnRows(serverRows("primary")).AnyTimes()
+	fakes[1].ExpectQuery("select server").WillReturnRows(serverRows("replica1")).AnyTimes()
+
+	if got := readServer(rs); got != "replica1" {
+		t.Errorf("read before write from %q, want replica1", got)
+	}
+	if err := rs.NonQuery("update orders set paid = true"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readServer(rs); got != "primary" {
+		t.Errorf("read right after write from %q, want primary", got)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := readServer(rs); got != "replica1" {
+		t.Errorf("read after StickyAfterWrite from %q, want replica1", got)
+	}
+}
+
+func TestReplicaSetFailover(t *testing.T) {
+	rs, fakes := newReplicaSet(t, 1)
+	fakes[0].ExpectQuery("select server").WillReturnRows(serverRows("primary")).AnyTimes()
+
+	// a statement error is the caller's, the primary is not tried and the replica stays healthy
+	fakes[1].ExpectQuery("select server").WillReturnError(errors.New(`relation "server" does not exist`))
+	if got := readServer(rs); got != "" {
+		t.Errorf("read with a statement error from %q, want a failed query", got)
+	}
+	if !rs.Healthy()[0] {
+		t.Error("replica unhealthy after a statement error")
+	}
+
+	// a connection error fails over to the primary and marks the replica down
+	fakes[1].ExpectQuery("select server").WillReturnError(driver.ErrBadConn).AnyTimes()
+	fakes[1].SetPingError(errors.New("connection refused"))
+	if got := readServer(rs); got != "primary" {
+		t.Errorf("read with a connection error from %q, want primary", got)
+	}
+	if rs.Healthy()[0] {
+		t.Error("replica healthy after a connection error")
+	}
+	if got := readServer(rs); got != "primary" {
+		t.Errorf("read with no healthy replica from %q, want primary", got)
+	}
+	queries := 0
+	for _, call := range fakes[0].Calls() {
+		if call.Kind == "query" {
+			queries++
+		}
+	}
+	if queries != 2 {
+		t.Errorf("primary ran %d queries, want 2 after the failover only", queries)
+	}
+
+	// the health check brings it back
+	fakes[1].SetPingError(nil)
+	rs.CheckHealth()
+	if !rs.Healthy()[0] {
+		t.Error("replica unhealthy after a successful ping")
+	}
+}
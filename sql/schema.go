@@ -0,0 +1,312 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SchemaInfo : tables and views of the connected database
+type SchemaInfo struct {
+	Tables []*TableInfo // tables and views sorted by schema, name
+}
+
+// TableInfo : one table or view
+type TableInfo struct {
+	Schema      string // dbo, public, ...
+	Name        string
+	IsView      bool
+	Columns     []ColumnInfo
+	PrimaryKey  []string // column names of the primary key, empty if none
+	Indexes     []IndexInfo
+	ForeignKeys []ForeignKeyInfo
+}
+
+// ColumnInfo : one column of a table or view
+type ColumnInfo struct {
+	Name       string
+	Position   int    // [1,n]
+	TypeName   string // same as DataReader.GetDataTypeName, e.g. NVARCHAR, INT4, NUMERIC
+	DataType   string // same as DataReader.GetDataTypeName2, e.g. NVARCHAR(50), NUMERIC(10,2)
+	Length     int64  // character length, -1 is MAX, 0 if not a character type
+	Precision  int64  // 0 if not a decimal type
+	Scale      int64
+	Nullable   bool
+	Default    string // default expression, empty if none
+	IsIdentity bool   // identity, serial or generated as identity
+}
+
+// IndexInfo : one index, primary key indexes included
+type IndexInfo struct {
+	Name    string
+	Columns []string // key columns in index order
+	Unique  bool
+	Primary bool
+}
+
+// ForeignKeyInfo : one foreign key constraint
+type ForeignKeyInfo struct {
+	Name       string
+	Columns    []string
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+	OnUpdate   string // NO ACTION, CASCADE, SET NULL, SET DEFAULT, RESTRICT
+	OnDelete   string
+}
+
+// Table : find a table or view by name, name may be qualified by schema e.g. "dbo.users"
+func (si *SchemaInfo) Table(name string) *TableInfo {
+	schema := ""
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		schema, name = name[:i], name[i+1:]
+	}
+	for _, t := range si.Tables {
+		if strings.EqualFold(t.Name, name) && (schema == "" || strings.EqualFold(t.Schema, schema)) {
+			return t
+		}
+	}
+	return nil
+}
+
+// Column : find a column by name, return nil if not found
+func (ti *TableInfo) Column(name string) *ColumnInfo {
+	for i := range ti.Columns {
+		if strings.EqualFold(ti.Columns[i].Name, name) {
+			return &ti.Columns[i]
+		}
+	}
+	return nil
+}
+
+// FullName : schema.name
+func (ti *TableInfo) FullName() string {
+	if ti.Schema == "" {
+		return ti.Name
+	}
+	return ti.Schema + "." + ti.Name
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+// every dialect returns the same column layout for each query so they can be scanned by the same code
+
+type schemaQueries struct {
+	tables      string // schema, name, is_view
+	columns     string // schema, table, name, position, type, length, precision, scale, nullable, default, identity
+	indexes     string // schema, table, index, unique, primary, column
+	foreignKeys string // schema, table, name, column, ref_schema, ref_table, ref_column, on_update, on_delete
+}
+
+var mssqlSchemaQueries = schemaQueries{
+	tables: `select TABLE_SCHEMA, TABLE_NAME, case when TABLE_TYPE = 'VIEW' then 1 else 0 end
+		from INFORMATION_SCHEMA.TABLES
+		order by TABLE_SCHEMA, TABLE_NAME`,
+	columns: `select c.TABLE_SCHEMA, c.TABLE_NAME, c.COLUMN_NAME, c.ORDINAL_POSITION, upper(c.DATA_TYPE),
+			c.CHARACTER_MAXIMUM_LENGTH,
+			case when c.DATA_TYPE in ('decimal', 'numeric') then c.NUMERIC_PRECISION end,
+			case when c.DATA_TYPE in ('decimal', 'numeric') then c.NUMERIC_SCALE end,
+			case when c.IS_NULLABLE = 'YES' then 1 else 0 end,
+			c.COLUMN_DEFAULT,
+			isnull(COLUMNPROPERTY(object_id(quotename(c.TABLE_SCHEMA) + '.' + quotename(c.TABLE_NAME)), c.COLUMN_NAME, 'IsIdentity'), 0)
+		from INFORMATION_SCHEMA.COLUMNS c
+		order by c.TABLE_SCHEMA, c.TABLE_NAME, c.ORDINAL_POSITION`,
+	indexes: `select s.name, t.name, i.name,
+			cast(i.is_unique as int), cast(i.is_primary_key as int), c.name
+		from sys.indexes i
+		join sys.objects t on t.object_id = i.object_id and t.type in ('U', 'V')
+		join sys.schemas s on s.schema_id = t.schema_id
+		join sys.index_columns ic on ic.object_id = i.object_id and ic.index_id = i.index_id and ic.is_included_column = 0
+		join sys.columns c on c.object_id = ic.object_id and c.column_id = ic.column_id
+		where i.name is not null
+		order by s.name, t.name, i.name, ic.key_ordinal`,
+	foreignKeys: `select s.name, t.name, fk.name, c.name, rs.name, rt.name, rc.name,
+			replace(fk.update_referential_action_desc, '_', ' '), replace(fk.delete_referential_action_desc, '_', ' ')
+		from sys.foreign_keys fk
+		join sys.foreign_key_columns fkc on fkc.constraint_object_id = fk.object_id
+		join sys.tables t on t.object_id = fk.parent_object_id
+		join sys.schemas s on s.schema_id = t.schema_id
+		join sys.columns c on c.object_id = fkc.parent_object_id and c.column_id = fkc.parent_column_id
+		join sys.tables rt on rt.object_id = fk.referenced_object_id
+		join sys.schemas rs on rs.schema_id = rt.schema_id
+		join sys.columns rc on rc.object_id = fkc.referenced_object_id and rc.column_id = fkc.referenced_column_id
+		order by s.name, t.name, fk.name, fkc.constraint_column_id`,
+}
+
+const postgresFkAction = `case %s when 'c' then 'CASCADE' when 'n' then 'SET NULL' when 'd' then 'SET DEFAULT'
+	when 'r' then 'RESTRICT' else 'NO ACTION' end`
+
+var postgresSchemaQueries = schemaQueries{
+	tables: `select table_schema, table_name, case when table_type = 'VIEW' then 1 else 0 end
+		from information_schema.tables
+		where table_schema not in ('pg_catalog', 'information_schema')
+		order by table_schema, table_name`,
+	columns: `select table_schema, table_name, column_name, ordinal_position, upper(udt_name),
+			character_maximum_length,
+			case when udt_name = 'numeric' then numeric_precision end,
+			case when udt_name = 'numeric' then numeric_scale end,
+			case when is_nullable = 'YES' then 1 else 0 end,
+			column_default,
+			case when is_identity = 'YES' or column_default like 'nextval(%' then 1 else 0 end
+		from information_schema.columns
+		where table_schema not in ('pg_catalog', 'information_schema')
+		order by table_schema, table_name, ordinal_position`,
+	indexes: `select n.nspname, t.relname, i.relname,
+			case when ix.indisunique then 1 else 0 end, case when ix.indisprimary then 1 else 0 end, a.attname
+		from pg_index ix
+		join pg_class t on t.oid = ix.indrelid
+		join pg_class i on i.oid = ix.indexrelid
+		join pg_namespace n on n.oid = t.relnamespace
+		cross join lateral unnest(ix.indkey) with ordinality as k(attnum, ord)
+		join pg_attribute a on a.attrelid = t.oid and a.attnum = k.attnum
+		where n.nspname not in ('pg_catalog', 'information_schema', 'pg_toast')
+		order by n.nspname, t.relname, i.relname, k.ord`,
+	foreignKeys: `select n.nspname, t.relname, c.conname, a.attname, rn.nspname, rt.relname, ra.attname,
+			` + fmt.Sprintf(postgresFkAction, "c.confupdtype") + `,
+			` + fmt.Sprintf(postgresFkAction, "c.confdeltype") + `
+		from pg_constraint c
+		join pg_class t on t.oid = c.conrelid
+		join pg_namespace n on n.oid = t.relnamespace
+		join pg_class rt on rt.oid = c.confrelid
+		join pg_namespace rn on rn.oid = rt.relnamespace
+		cross join lateral unnest(c.conkey, c.confkey) with ordinality as k(attnum, refattnum, ord)
+		join pg_attribute a on a.attrelid = c.conrelid and a.attnum = k.attnum
+		join pg_attribute ra on ra.attrelid = c.confrelid and ra.attnum = k.refattnum
+		where c.contype = 'f'
+		order by n.nspname, t.relname, c.conname, k.ord`,
+}
+
+// Schema : read tables, views, columns, primary keys, indexes and foreign keys of the connected database
+func (bc *baseConnector) Schema() (*SchemaInfo, error) {
+	var q schemaQueries
+	switch bc.dialect {
+	case DialectMssql:
+		q = mssqlSchemaQueries
+	case DialectPostgres:
+		q = postgresSchemaQueries
+	default:
+		return nil, fmt.Errorf("schema: unsupported dialect %s", bc.dialect)
+	}
+
+	output := &SchemaInfo{}
+	index := map[string]*TableInfo{}
+	key := func(schema, table string) string { return schema + "\x00" + table }
+	lookup := func(schema, table string) *TableInfo {
+		return index[key(schema, table)]
+	}
+
+	err := bc.scanRows(q.tables, func(rows *sql.Rows) error {
+		t := &TableInfo{}
+		if err := rows.Scan(&t.Schema, &t.Name, &t.IsView); err != nil {
+			return err
+		}
+		output.Tables = append(output.Tables, t)
+		index[key(t.Schema, t.Name)] = t
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("schema: tables: %w", err)
+	}
+
+	err = bc.scanRows(q.columns, func(rows *sql.Rows) error {
+		var schema, table string
+		var length, precision, scale sql.NullInt64
+		var def sql.NullString
+		c := ColumnInfo{}
+		err := rows.Scan(&schema, &table, &c.Name, &c.Position, &c.TypeName,
+			&length, &precision, &scale, &c.Nullable, &def, &c.IsIdentity)
+		if err != nil {
+			return err
+		}
+		c.Length, c.Precision, c.Scale, c.Default = length.Int64, precision.Int64, scale.Int64, def.String
+		c.DataType = formatTypeName(c.TypeName, c.Length, length.Valid, c.Precision, c.Scale, precision.Valid)
+		if t := lookup(schema, table); t != nil {
+			t.Columns = append(t.Columns, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("schema: columns: %w", err)
+	}
+
+	err = bc.scanRows(q.indexes, func(rows *sql.Rows) error {
+		var schema, table, name, column string
+		var unique, primary bool
+		if err := rows.Scan(&schema, &table, &name, &unique, &primary, &column); err != nil {
+			return err
+		}
+		t := lookup(schema, table)
+		if t == nil {
+			return nil
+		}
+		if n := len(t.Indexes); n == 0 || t.Indexes[n-1].Name != name {
+			t.Indexes = append(t.Indexes, IndexInfo{Name: name, Unique: unique, Primary: primary})
+		}
+		ix := &t.Indexes[len(t.Indexes)-1]
+		ix.Columns = append(ix.Columns, column)
+		if primary {
+			t.PrimaryKey = append(t.PrimaryKey, column)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("schema: indexes: %w", err)
+	}
+
+	err = bc.scanRows(q.foreignKeys, func(rows *sql.Rows) error {
+		var schema, table, name, column, refSchema, refTable, refColumn, onUpdate, onDelete string
+		err := rows.Scan(&schema, &table, &name, &column, &refSchema, &refTable, &refColumn, &onUpdate, &onDelete)
+		if err != nil {
+			return err
+		}
+		t := lookup(schema, table)
+		if t == nil {
+			return nil
+		}
+		if n := len(t.ForeignKeys); n == 0 || t.ForeignKeys[n-1].Name != name {
+			t.ForeignKeys = append(t.ForeignKeys, ForeignKeyInfo{Name: name, RefSchema: refSchema, RefTable: refTable,
+				OnUpdate: onUpdate, OnDelete: onDelete})
+		}
+		fk := &t.ForeignKeys[len(t.ForeignKeys)-1]
+		fk.Columns = append(fk.Columns, column)
+		fk.RefColumns = append(fk.RefColumns, refColumn)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("schema: foreign keys: %w", err)
+	}
+
+	return output, nil
+}
+
+// scanRows : run a metadata query and call fn for every row
+func (bc *baseConnector) scanRows(QueryString string, fn func(rows *sql.Rows) error) error {
+	if bc.db == nil {
+		return ErrNotOpen
+	}
+	rows, err := bc.db.Query(QueryString)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// formatTypeName : TYPE, TYPE(length) or TYPE(precision,scale), length -1 is written as MAX
+func formatTypeName(name string, length int64, hasLength bool, precision, scale int64, hasDecimal bool) string {
+	output := name
+	if hasLength {
+		if length < 0 {
+			output += "(MAX)"
+		} else {
+			output += fmt.Sprintf("(%d)", length)
+		}
+	} else if hasDecimal {
+		output += fmt.Sprintf("(%d,%d)", precision, scale)
+	}
+	return output
+}
@@ -0,0 +1,143 @@
+package sql_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/churikawit/gosrc/sql"
+	"github.com/churikawit/gosrc/sql/sqltest"
+)
+
+// expectSchema : answer the four metadata queries of Schema, patterns select the query of each dialect
+func expectSchema(fake *sqltest.DB, patterns [4]string, tables, columns, indexes, foreignKeys *sqltest.Rows) {
+	fake.ExpectQueryRegexp(patterns[0]).WillReturnRows(tables)
+	fake.ExpectQueryRegexp(patterns[1]).WillReturnRows(columns)
+	fake.ExpectQueryRegexp(patterns[2]).WillReturnRows(indexes)
+	fake.ExpectQueryRegexp(patterns[3]).WillReturnRows(foreignKeys)
+}
+
+func schemaRows(names ...string) *sqltest.Rows {
+	columns := make([]sqltest.Column, len(names))
+	for i, n := range names {
+		columns[i] = sqltest.Column{Name: n, TypeName: "NVARCHAR", Nullable: true}
+	}
+	return sqltest.NewRows(columns...)
+}
+
+func TestSchemaMssql(t *testing.T) {
+	conn, fake := openMssql(t)
+	expectSchema(fake,
+		[4]string{`INFORMATION_SCHEMA\.TABLES`, `INFORMATION_SCHEMA\.COLUMNS`, `sys\.indexes`, `sys\.foreign_keys`},
+		schemaRows("schema", "name", "is_view").
+			AddRow("dbo", "log_event", 0).
+			AddRow("dbo", "register_user", 0).
+			AddRow("dbo", "v_user", 1),
+		schemaRows("schema", "table", "name", "position", "type", "length", "precision", "scale", "nullable", "default", "identity").
+			AddRow("dbo", "log_event", "id", 1, "INT", nil, nil, nil, 0, nil, 1).
+			AddRow("dbo", "log_event", "user_id", 2, "NVARCHAR", 50, nil, nil, 0, nil, 0).
+			AddRow("dbo", "log_event", "payload", 3, "NVARCHAR", -1, nil, nil, 1, nil, 0).
+			AddRow("dbo", "log_event", "amount", 4, "DECIMAL", nil, 10, 2, 1, "((0))", 0).
+			AddRow("dbo", "register_user", "user_id", 1, "NVARCHAR", 50, nil, nil, 0, nil, 0).
+			AddRow("dbo", "v_user", "user_id", 1, "NVARCHAR", 50, nil, nil, 0, nil, 0).
+			AddRow("other", "missing", "x", 1, "INT", nil, nil, nil, 1, nil, 0),
+		schemaRows("schema", "table", "index", "unique", "primary", "column").
+			AddRow("dbo", "log_event", "IX_log_event_user", 0, 0, "user_id").
+			AddRow("dbo", "log_event", "IX_log_event_user", 0, 0, "id").
+			AddRow("dbo", "log_event", "PK_log_event", 1, 1, "id"),
+		schemaRows("schema", "table", "name", "column", "ref_schema", "ref_table", "ref_column", "on_update", "on_delete").
+			AddRow("dbo", "log_event", "FK_log_event_user", "user_id", "dbo", "register_user", "user_id", "NO ACTION", "CASCADE"))
+
+	si, err := conn.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(si.Tables) != 3 || !si.Table("v_user").IsView || si.Table("log_event").IsView {
+		t.Fatalf("Tables == %+v", si.Tables)
+	}
+	table := si.Table("dbo.log_event")
+	wantColumns := []sql.ColumnInfo{
+		{Name: "id", Position: 1, TypeName: "INT", DataType: "INT", IsIdentity: true},
+		{Name: "user_id", Position: 2, TypeName: "NVARCHAR", DataType: "NVARCHAR(50)", Length: 50},
+		{Name: "payload", Position: 3, TypeName: "NVARCHAR", DataType: "NVARCHAR(MAX)", Length: -1, Nullable: true},
+		{Name: "amount", Position: 4, TypeName: "DECIMAL", DataType: "DECIMAL(10,2)", Precision: 10, Scale: 2,
+			Nullable: true, Default: "((0))"},
+	}
+	if !reflect.DeepEqual(table.Columns, wantColumns) {
+		t.Errorf("Columns == %+v, want %+v", table.Columns, wantColumns)
+	}
+	if !reflect.DeepEqual(table.PrimaryKey, []string{"id"}) {
+		t.Errorf("PrimaryKey == %v, want [id]", table.PrimaryKey)
+	}
+	wantIndexes := []sql.IndexInfo{
+		{Name: "IX_log_event_user", Columns: []string{"user_id", "id"}},
+		{Name: "PK_log_event", Columns: []string{"id"}, Unique: true, Primary: true},
+	}
+	if !reflect.DeepEqual(table.Indexes, wantIndexes) {
+		t.Errorf("Indexes == %+v, want %+v", table.Indexes, wantIndexes)
+	}
+	wantKeys := []sql.ForeignKeyInfo{{Name: "FK_log_event_user", Columns: []string{"user_id"}, RefSchema: "dbo",
+		RefTable: "register_user", RefColumns: []string{"user_id"}, OnUpdate: "NO ACTION", OnDelete: "CASCADE"}}
+	if !reflect.DeepEqual(table.ForeignKeys, wantKeys) {
+		t.Errorf("ForeignKeys == %+v, want %+v", table.ForeignKeys, wantKeys)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSchemaPostgres(t *testing.T) {
+	conn, fake := openPostgres(t)
+	expectSchema(fake,
+		[4]string{`information_schema\.tables`, `information_schema\.columns`, `pg_index`, `pg_constraint`},
+		schemaRows("schema", "name", "is_view").
+			AddRow("public", "bot", 0).
+			AddRow("public", "register_user", 0),
+		schemaRows("schema", "table", "name", "position", "type", "length", "precision", "scale", "nullable", "default", "identity").
+			AddRow("public", "bot", "bot_id", 1, "INT4", nil, nil, nil, 0, "nextval('bot_bot_id_seq'::regclass)", 1).
+			AddRow("public", "register_user", "user_id", 1, "VARCHAR", 50, nil, nil, 0, nil, 0).
+			AddRow("public", "register_user", "bot_id", 2, "INT4", nil, nil, nil, 0, nil, 0).
+			AddRow("public", "register_user", "score", 3, "NUMERIC", nil, 12, 4, 1, nil, 0),
+		schemaRows("schema", "table", "index", "unique", "primary", "column").
+			AddRow("public", "bot", "bot_pkey", 1, 1, "bot_id").
+			AddRow("public", "register_user", "register_user_pkey", 1, 1, "user_id").
+			AddRow("public", "register_user", "register_user_pkey", 1, 1, "bot_id"),
+		schemaRows("schema", "table", "name", "column", "ref_schema", "ref_table", "ref_column", "on_update", "on_delete").
+			AddRow("public", "register_user", "register_user_bot_fk", "bot_id", "public", "bot", "bot_id", "NO ACTION", "RESTRICT"))
+
+	si, err := conn.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot := si.Table("bot")
+	if bot == nil || len(bot.Columns) != 1 || !bot.Columns[0].IsIdentity || bot.Columns[0].DataType != "INT4" {
+		t.Fatalf("bot == %+v", bot)
+	}
+	user := si.Table("public.register_user")
+	if got := user.Column("SCORE"); got == nil || got.DataType != "NUMERIC(12,4)" || !got.Nullable {
+		t.Errorf("Column(SCORE) == %+v", got)
+	}
+	if got := user.Column("user_id"); got == nil || got.DataType != "VARCHAR(50)" || got.Nullable {
+		t.Errorf("Column(user_id) == %+v", got)
+	}
+	if !reflect.DeepEqual(user.PrimaryKey, []string{"user_id", "bot_id"}) {
+		t.Errorf("PrimaryKey == %v, want [user_id bot_id]", user.PrimaryKey)
+	}
+	if len(user.Indexes) != 1 || !user.Indexes[0].Primary || !user.Indexes[0].Unique {
+		t.Errorf("Indexes == %+v", user.Indexes)
+	}
+	if len(user.ForeignKeys) != 1 || user.ForeignKeys[0].RefTable != "bot" || user.ForeignKeys[0].OnDelete != "RESTRICT" {
+		t.Errorf("ForeignKeys == %+v", user.ForeignKeys)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSchemaQueryError(t *testing.T) {
+	conn, fake := openPostgres(t)
+	fake.ExpectQueryRegexp(`information_schema\.tables`).WillReturnError(sql.ErrNotOpen)
+	if _, err := conn.Schema(); !errors.Is(err, sql.ErrNotOpen) {
+		t.Errorf("Schema() error == %v, want the query error", err)
+	}
+}
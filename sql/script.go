@@ -0,0 +1,266 @@
+package sql
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Batch : one piece of a script between batch separators
+type Batch struct {
+	SQL       string
+	StartLine int // [1,n], line of the script where the batch starts
+	EndLine   int
+	Count     int // number of times to run, "GO 5" gives 5, otherwise 1
+}
+
+// ScriptOptions : options of RunScript
+type ScriptOptions struct {
+	// InTransaction runs every batch in one transaction, rolled back if any batch fails
+	InTransaction bool
+}
+
+// ScriptError : a batch of a script failed
+type ScriptError struct {
+	Batch     int // [1,n]
+	StartLine int
+	EndLine   int
+	SQL       string
+	Err       error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("script batch %d (line %d-%d): %v", e.Batch, e.StartLine, e.EndLine, e.Err)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// RunScript : split a script into batches (see SplitScript) and run them in order
+// return number of batches run, on failure the error is a *ScriptError
+func (bc *baseConnector) RunScript(script string, opts ScriptOptions) (int, error) {
+	batches := SplitScript(script, bc.dialect)
+	if !opts.InTransaction {
+		return runBatches(bc, batches)
+	}
+
+	count := 0
+	err := bc.InTransaction(func(tx *Transaction) error {
+		var err error
+		count, err = runBatches(tx, batches)
+		return err
+	})
+	if err != nil {
+		return 0, err // rolled back, nothing applied
+	}
+	return count, nil
+}
+
+// RunScriptFile : read a .sql file and run it with RunScript
+func (bc *baseConnector) RunScriptFile(filename string, opts ScriptOptions) (int, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	count, err := bc.RunScript(string(data), opts)
+	if err != nil {
+		return count, fmt.Errorf("%s: %w", filename, err)
+	}
+	return count, nil
+}
+
+func runBatches(e executor, batches []Batch) (int, error) {
+	for i, b := range batches {
+		for j := 0; j < b.Count; j++ {
+			if _, err := e.exec(b.SQL); err != nil {
+				return i, &ScriptError{Batch: i + 1, StartLine: b.StartLine, EndLine: b.EndLine, SQL: b.SQL, Err: err}
+			}
+		}
+	}
+	return len(batches), nil
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+// SplitScript : split a script into batches
+// mssql splits on lines holding only "GO" or "GO <count>", postgres splits on ";"
+// separators inside strings, quoted names and comments are ignored, empty batches are dropped
+func SplitScript(script string, dialect Dialect) []Batch {
+	script = strings.Replace(script, "\r\n", "\n", -1)
+	if dialect == DialectMssql {
+		return splitGoBatches(script)
+	}
+	return splitStatements(script)
+}
+
+var reGoSeparator = regexp.MustCompile(`(?i)^\s*go(?:\s+(\d+))?\s*(?:--.*)?$`)
+
+// splitGoBatches : T-SQL scripts, GO must be alone on its line outside any string or block comment
+func splitGoBatches(script string) []Batch {
+	var output []Batch
+	var lines []string
+	startLine := 1
+	var quote byte // 0, '\'', '"' or ']' while inside a string or quoted name
+	commentDepth := 0
+
+	// flush : end the batch before line index n (the GO line)
+	flush := func(n, count int) {
+		addBatch(&output, strings.Join(lines, "\n"), startLine, count)
+		lines = lines[:0]
+		startLine = n + 2
+	}
+
+	for n, line := range strings.Split(script, "\n") {
+		if quote == 0 && commentDepth == 0 {
+			if m := reGoSeparator.FindStringSubmatch(line); m != nil {
+				count := 1
+				if m[1] != "" {
+					count, _ = strconv.Atoi(m[1])
+				}
+				flush(n, count)
+				continue
+			}
+		}
+		lines = append(lines, line)
+
+		for i := 0; i < len(line); i++ {
+			ch := line[i]
+			next := byte(0)
+			if i+1 < len(line) {
+				next = line[i+1]
+			}
+			switch {
+			case commentDepth > 0:
+				if ch == '*' && next == '/' {
+					commentDepth--
+					i++
+				} else if ch == '/' && next == '*' {
+					commentDepth++
+					i++
+				}
+			case quote != 0:
+				if ch == quote {
+					if next == quote {
+						i++ // escaped '' "" or ]]
+					} else {
+						quote = 0
+					}
+				}
+			case ch == '-' && next == '-':
+				i = len(line)
+			case ch == '/' && next == '*':
+				commentDepth++
+				i++
+			case ch == '\'' || ch == '"':
+				quote = ch
+			case ch == '[':
+				quote = ']'
+			}
+		}
+	}
+	flush(0, 1)
+	return output
+}
+
+// splitStatements : postgres scripts, split on ";" outside strings, dollar quotes, quoted names and comments
+func splitStatements(script string) []Batch {
+	var output []Batch
+	start, startLine, line := 0, 1, 1
+	commentDepth := 0
+
+	for i := 0; i < len(script); i++ {
+		ch := script[i]
+		next := byte(0)
+		if i+1 < len(script) {
+			next = script[i+1]
+		}
+
+		switch {
+		case ch == '\n':
+			line++
+		case commentDepth > 0:
+			if ch == '*' && next == '/' {
+				commentDepth--
+				i++
+			} else if ch == '/' && next == '*' {
+				commentDepth++
+				i++
+			}
+		case ch == '-' && next == '-':
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			i-- // let the loop count the newline
+		case ch == '/' && next == '*':
+			commentDepth++
+			i++
+		case ch == '\'' || ch == '"':
+			// E'..' strings allow backslash escapes
+			backslash := ch == '\'' && i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') &&
+				(i < 2 || !isIdentChar(script[i-2]))
+			for i++; i < len(script); i++ {
+				if script[i] == '\n' {
+					line++
+				} else if backslash && script[i] == '\\' {
+					i++
+				} else if script[i] == ch {
+					if i+1 < len(script) && script[i+1] == ch {
+						i++
+					} else {
+						break
+					}
+				}
+			}
+		case ch == '$' && (i == 0 || !isIdentChar(script[i-1])):
+			tag := dollarTag(script[i:])
+			if tag == "" {
+				continue
+			}
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				end = len(script) - i - len(tag)
+			} else {
+				end += len(tag)
+			}
+			line += strings.Count(script[i:i+len(tag)+end], "\n")
+			i += len(tag) + end - 1
+		case ch == ';':
+			addBatch(&output, script[start:i], startLine, 1)
+			start, startLine = i+1, line
+		}
+	}
+	addBatch(&output, script[start:], startLine, 1)
+	return output
+}
+
+// dollarTag : return $tag$ or $$ at the start of s, empty if s does not start a dollar quote
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return s[:i+1]
+		}
+		if !isIdentChar(s[i]) || (i == 1 && s[i] >= '0' && s[i] <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || ch >= 0x80 || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')
+}
+
+// addBatch : append text as a batch unless it is blank, StartLine skips leading blank lines
+func addBatch(output *[]Batch, text string, startLine, count int) {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" || count <= 0 {
+		return
+	}
+	lead := text[:strings.Index(text, trimmed)]
+	startLine += strings.Count(lead, "\n")
+	endLine := startLine + strings.Count(trimmed, "\n")
+	*output = append(*output, Batch{SQL: trimmed, StartLine: startLine, EndLine: endLine, Count: count})
+}
@@ -0,0 +1,60 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitScriptMssql(t *testing.T) {
+	script := "create table a(id int)\n" +
+		"GO\n" +
+		"\n" +
+		"insert into a values(1) -- go\n" +
+		"insert into a values(2)\n" +
+		"  go 3  \n" +
+		"/* comment\n" +
+		"GO\n" +
+		"*/ select 'it''s\n" +
+		"GO\n" +
+		"'\n" +
+		"go\n" +
+		"go\n" +
+		"select [go\n" +
+		"go]"
+
+	want := []Batch{
+		{"create table a(id int)", 1, 1, 1},
+		{"insert into a values(1) -- go\ninsert into a values(2)", 4, 5, 3},
+		{"/* comment\nGO\n*/ select 'it''s\nGO\n'", 7, 11, 1},
+		{"select [go\ngo]", 14, 15, 1},
+	}
+	got := SplitScript(script, DialectMssql)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitScript(mssql) ==\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestSplitScriptPostgres(t *testing.T) {
+	script := "create table a(id int);\n" +
+		"insert into a values(1); insert into a values(2);\n" +
+		"-- a comment; with semicolon\n" +
+		"select 'a;b', \"c;d\", E'e\\';f';\n" +
+		"create function f() returns int as $body$\n" +
+		"begin return 1; end;\n" +
+		"$body$ language plpgsql;\n" +
+		"/* x; /* nested; */ y; */ select $1;\n" +
+		"\n"
+
+	want := []Batch{
+		{"create table a(id int)", 1, 1, 1},
+		{"insert into a values(1)", 2, 2, 1},
+		{"insert into a values(2)", 2, 2, 1},
+		{"-- a comment; with semicolon\nselect 'a;b', \"c;d\", E'e\\';f'", 3, 4, 1},
+		{"create function f() returns int as $body$\nbegin return 1; end;\n$body$ language plpgsql", 5, 7, 1},
+		{"/* x; /* nested; */ y; */ select $1", 8, 8, 1},
+	}
+	got := SplitScript(script, DialectPostgres)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitScript(postgres) ==\n%#v\nwant\n%#v", got, want)
+	}
+}
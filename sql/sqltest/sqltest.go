@@ -0,0 +1,501 @@
+// Package sqltest : in-process fake database/sql driver for unit tests
+//
+// tests register the statements they expect with canned results, then point a connector at the fake:
+//
+//	fake := sqltest.New()
+//	fake.ExpectQuery("select user_id, bot_id from register_user where bot_id=$1").
+//		WithArgs(1).
+//		WillReturnRows(sqltest.NewRows(
+//			sqltest.Column{Name: "user_id", TypeName: "VARCHAR", Length: 64},
+//			sqltest.Column{Name: "bot_id", TypeName: "INT"},
+//		).AddRow("U1", int64(1)))
+//
+//	conn := sql.NewPostgresConnector2(fake.DSN())
+//	conn.DriverName = sqltest.DriverName
+//	conn.OpenConnection()
+//	reader := conn.Query("select user_id, bot_id from register_user where bot_id=$1", 1)
+//	...
+//	if err := fake.ExpectationsWereMet(); err != nil {
+//		t.Error(err)
+//	}
+//
+// MssqlConnector.OpenConnection reads SELECT @@version, stub it with
+// fake.ExpectQuery("SELECT @@version").WillReturnRows(...).AnyTimes()
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DriverName : name of the fake driver in database/sql
+const DriverName = "sqltest"
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*DB{}
+	counter    int
+)
+
+func init() {
+	sql.Register(DriverName, fakeDriver{})
+}
+
+// DB : one fake database, its expectations and the statements it received
+type DB struct {
+	dsn string
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	calls        []Call
+	unexpected   []string
+	pingErr      error
+}
+
+// Call : one statement received by the fake, Kind is "query", "exec", "begin", "commit" or "rollback"
+type Call struct {
+	Kind  string
+	Query string
+	Args  []interface{}
+}
+
+// New : a new fake database, each has its own DSN
+func New() *DB {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	counter++
+	db := &DB{dsn: fmt.Sprintf("sqltest-%d", counter)}
+	registry[db.dsn] = db
+	return db
+}
+
+// DSN : data source name to open this fake with the DriverName driver
+func (db *DB) DSN() string {
+	return db.dsn
+}
+
+// Open : sql.Open(DriverName, DSN())
+func (db *DB) Open() (*sql.DB, error) {
+	return sql.Open(DriverName, db.dsn)
+}
+
+// SetPingError : make Ping return err, nil restores success
+func (db *DB) SetPingError(err error) {
+	db.mu.Lock()
+	db.pingErr = err
+	db.mu.Unlock()
+}
+
+// ExpectQuery : expect a statement run with Query, compared after collapsing whitespace
+func (db *DB) ExpectQuery(query string) *Expectation {
+	return db.expect("query", query, nil)
+}
+
+// ExpectQueryRegexp : expect a Query whose text matches pattern
+func (db *DB) ExpectQueryRegexp(pattern string) *Expectation {
+	return db.expect("query", "", regexp.MustCompile(pattern))
+}
+
+// ExpectExec : expect a statement run with Exec (NonQuery), compared after collapsing whitespace
+func (db *DB) ExpectExec(query string) *Expectation {
+	return db.expect("exec", query, nil)
+}
+
+// ExpectExecRegexp : expect an Exec whose text matches pattern
+func (db *DB) ExpectExecRegexp(pattern string) *Expectation {
+	return db.expect("exec", "", regexp.MustCompile(pattern))
+}
+
+func (db *DB) expect(kind, query string, re *regexp.Regexp) *Expectation {
+	e := &Expectation{kind: kind, query: normalize(query), re: re, times: 1, result: driver.RowsAffected(0)}
+	db.mu.Lock()
+	db.expectations = append(db.expectations, e)
+	db.mu.Unlock()
+	return e
+}
+
+// Calls : every statement received so far, in order
+func (db *DB) Calls() []Call {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]Call(nil), db.calls...)
+}
+
+// ExpectationsWereMet : error listing expectations not used up and statements nobody expected
+func (db *DB) ExpectationsWereMet() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var problems []string
+	for _, e := range db.expectations {
+		if e.times > 0 && e.used < e.times {
+			problems = append(problems, fmt.Sprintf("expected %s %s %d time(s), got %d", e.kind, e.describe(), e.times, e.used))
+		}
+	}
+	problems = append(problems, db.unexpected...)
+	if len(problems) > 0 {
+		return fmt.Errorf("sqltest: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// match : first expectation not used up that accepts the statement
+func (db *DB) match(kind, query string, args []driver.NamedValue) (*Expectation, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	values := make([]interface{}, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	db.calls = append(db.calls, Call{Kind: kind, Query: query, Args: values})
+
+	for _, e := range db.expectations {
+		if e.kind != kind || (e.times > 0 && e.used >= e.times) {
+			continue
+		}
+		if !e.matchQuery(query) || !e.matchArgs(values) {
+			continue
+		}
+		e.used++
+		return e, nil
+	}
+	msg := fmt.Sprintf("unexpected %s %q with args %v", kind, query, values)
+	db.unexpected = append(db.unexpected, msg)
+	return nil, fmt.Errorf("sqltest: %s", msg)
+}
+
+func (db *DB) record(kind string) {
+	db.mu.Lock()
+	db.calls = append(db.calls, Call{Kind: kind})
+	db.mu.Unlock()
+}
+
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+// anyArg : matches any argument value
+type anyArg struct{}
+
+// AnyArg : placeholder for WithArgs that matches any value
+func AnyArg() interface{} {
+	return anyArg{}
+}
+
+// Expectation : one expected statement and its canned result
+type Expectation struct {
+	kind    string
+	query   string
+	re      *regexp.Regexp
+	args    []interface{}
+	hasArgs bool
+	rows    *Rows
+	result  driver.Result
+	err     error
+	times   int // 0 means any number of times
+	used    int
+}
+
+// WithArgs : arguments the statement must be called with, use AnyArg() to accept any value
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.args = args
+	e.hasArgs = true
+	return e
+}
+
+// WillReturnRows : result set of a query
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnResult : last insert id and rows affected of an exec
+func (e *Expectation) WillReturnResult(lastInsertID, rowsAffected int64) *Expectation {
+	e.result = result{lastInsertID, rowsAffected}
+	return e
+}
+
+// WillReturnError : make the statement fail with err
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Times : number of calls expected, default 1
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// AnyTimes : accept any number of calls, including none
+func (e *Expectation) AnyTimes() *Expectation {
+	e.times = 0
+	return e
+}
+
+func (e *Expectation) describe() string {
+	if e.re != nil {
+		return fmt.Sprintf("matching %q", e.re.String())
+	}
+	return fmt.Sprintf("%q", e.query)
+}
+
+func (e *Expectation) matchQuery(query string) bool {
+	if e.re != nil {
+		return e.re.MatchString(query)
+	}
+	return e.query == normalize(query)
+}
+
+func (e *Expectation) matchArgs(values []interface{}) bool {
+	if !e.hasArgs {
+		return true
+	}
+	if len(values) != len(e.args) {
+		return false
+	}
+	for i, want := range e.args {
+		if _, ok := want.(anyArg); ok {
+			continue
+		}
+		converted, err := driver.DefaultParameterConverter.ConvertValue(want)
+		if err != nil {
+			converted = want
+		}
+		if !reflect.DeepEqual(converted, values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+type result struct {
+	lastInsertID, rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r result) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+// -----------------------------------------------------------------------------------------------------------------------------
+
+// Column : column of a canned result set
+// TypeName is what DataReader.GetDataTypeName returns e.g. NVARCHAR, INT, DECIMAL, DATE,
+// Length or Precision/Scale feed GetDataTypeName2
+type Column struct {
+	Name      string
+	TypeName  string
+	Length    int64 // 0 means no length
+	Precision int64 // 0 means no precision and scale
+	Scale     int64
+	Nullable  bool
+}
+
+// Rows : canned result set, values are driver values: nil, int64, float64, bool, []byte, string, time.Time
+type Rows struct {
+	columns []Column
+	values  [][]driver.Value
+}
+
+// NewRows : empty result set with columns
+func NewRows(columns ...Column) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow : append a row, one value per column
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("sqltest: AddRow with %d values for %d columns", len(values), len(r.columns)))
+	}
+	row := make([]driver.Value, len(values))
+	for i, v := range values {
+		converted, err := driver.DefaultParameterConverter.ConvertValue(v)
+		if err != nil {
+			panic(fmt.Sprintf("sqltest: AddRow value %d: %v", i, err))
+		}
+		row[i] = converted
+	}
+	r.values = append(r.values, row)
+	return r
+}
+
+// -----------------------------------------------------------------------------------------------------------------------------
+// database/sql driver
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	registryMu.Lock()
+	db, ok := registry[name]
+	registryMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("sqltest: unknown dsn %q, use sqltest.New().DSN()", name)
+	}
+	return &conn{db: db}, nil
+}
+
+type conn struct {
+	db *DB
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c, query: query}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	c.db.record("begin")
+	return tx{c.db}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Begin()
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	return c.db.pingErr
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.query(query, args)
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.exec(query, args)
+}
+
+// CheckNamedValue : accept every argument type, so tests see the values exactly as passed
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err == nil {
+		nv.Value = v
+	}
+	return nil
+}
+
+func (c *conn) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.db.match("query", query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.rows == nil {
+		return &rows{set: &Rows{}}, nil
+	}
+	return &rows{set: e.rows}, nil
+}
+
+func (c *conn) exec(query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.db.match("exec", query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.result, nil
+}
+
+type tx struct {
+	db *DB
+}
+
+func (t tx) Commit() error {
+	t.db.record("commit")
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.db.record("rollback")
+	return nil
+}
+
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.exec(s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.query(s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	output := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		output[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return output
+}
+
+var (
+	_ driver.QueryerContext                 = (*conn)(nil)
+	_ driver.ExecerContext                  = (*conn)(nil)
+	_ driver.ConnBeginTx                    = (*conn)(nil)
+	_ driver.Pinger                         = (*conn)(nil)
+	_ driver.NamedValueChecker              = (*conn)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*rows)(nil)
+	_ driver.RowsColumnTypeLength           = (*rows)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*rows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*rows)(nil)
+)
+
+type rows struct {
+	set *Rows
+	pos int
+}
+
+func (r *rows) Columns() []string {
+	names := make([]string, len(r.set.columns))
+	for i, c := range r.set.columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func (r *rows) Close() error { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.set.values) {
+		return io.EOF
+	}
+	copy(dest, r.set.values[r.pos])
+	r.pos++
+	return nil
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.set.columns[index].TypeName
+}
+
+func (r *rows) ColumnTypeLength(index int) (int64, bool) {
+	c := r.set.columns[index]
+	return c.Length, c.Length != 0
+}
+
+func (r *rows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	c := r.set.columns[index]
+	return c.Precision, c.Scale, c.Precision != 0
+}
+
+func (r *rows) ColumnTypeNullable(index int) (bool, bool) {
+	return r.set.columns[index].Nullable, true
+}
@@ -0,0 +1,88 @@
+package sql
+
+import (
+	"database/sql"
+)
+
+// Transaction : wrapper for sql.Tx, statements go through the hooks and logger of the connector
+type Transaction struct {
+	tx *sql.Tx
+	bc *baseConnector
+}
+
+// Begin : start a transaction
+func (bc *baseConnector) Begin() (*Transaction, error) {
+	if bc.db == nil {
+		return nil, ErrNotOpen
+	}
+	tx, err := bc.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{tx: tx, bc: bc}, nil
+}
+
+// InTransaction : run fn in a transaction, commit if fn return nil, otherwise rollback
+func (bc *baseConnector) InTransaction(fn func(tx *Transaction) error) error {
+	tx, err := bc.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Query : run a statement returning rows, return nil if error
+func (t *Transaction) Query(QueryString string, args ...interface{}) *DataReader {
+	reader, err := t.query(QueryString, args...)
+	if err != nil {
+		t.bc.logger().Log(LevelError, "query failed", "error", err)
+		return nil
+	}
+	return reader
+}
+
+// NonQuery : run a statement without reading any rows
+func (t *Transaction) NonQuery(QueryString string, args ...interface{}) error {
+	_, err := t.exec(QueryString, args...)
+	if err != nil {
+		t.bc.logger().Log(LevelError, "non-query failed", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (t *Transaction) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *Transaction) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// Dialect : return the SQL flavour of the connector
+func (t *Transaction) Dialect() Dialect {
+	return t.bc.dialect
+}
+
+func (t *Transaction) query(QueryString string, args ...interface{}) (*DataReader, error) {
+	return t.bc.queryOn(t.tx, QueryString, args...)
+}
+
+func (t *Transaction) exec(QueryString string, args ...interface{}) (sql.Result, error) {
+	return t.bc.execOn(t.tx, QueryString, args...)
+}
+
+var (
+	_ executor = (*baseConnector)(nil)
+	_ executor = (*Transaction)(nil)
+)
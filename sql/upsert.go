@@ -0,0 +1,145 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Upsert : insert a row, or update it if a row with the same key columns exists, in one atomic statement
+// postgres uses INSERT ... ON CONFLICT DO UPDATE, keyColumns must match a primary key or unique index
+// mssql uses MERGE WITH (HOLDLOCK)
+// values maps column name to value and must contain every key column, return true if the row was inserted
+func (bc *baseConnector) Upsert(table string, keyColumns []string, values map[string]interface{}) (bool, error) {
+	return upsert(bc, table, keyColumns, values, nil)
+}
+
+// Upsert2 : same as Upsert, insertOnly values are written when the row is inserted and kept on update, e.g. create_time
+func (bc *baseConnector) Upsert2(table string, keyColumns []string, values, insertOnly map[string]interface{}) (bool, error) {
+	return upsert(bc, table, keyColumns, values, insertOnly)
+}
+
+// Upsert : same as the connector Upsert, inside the transaction
+func (t *Transaction) Upsert(table string, keyColumns []string, values map[string]interface{}) (bool, error) {
+	return upsert(t, table, keyColumns, values, nil)
+}
+
+// Upsert2 : same as the connector Upsert2, inside the transaction
+func (t *Transaction) Upsert2(table string, keyColumns []string, values, insertOnly map[string]interface{}) (bool, error) {
+	return upsert(t, table, keyColumns, values, insertOnly)
+}
+
+func upsert(e executor, table string, keyColumns []string, values, insertOnly map[string]interface{}) (bool, error) {
+	QueryString, args, err := buildUpsert(e.Dialect(), table, keyColumns, values, insertOnly)
+	if err != nil {
+		return false, err
+	}
+	reader, err := e.query(QueryString, args...)
+	if err != nil {
+		return false, fmt.Errorf("upsert %s: %w", table, err)
+	}
+	defer reader.Close()
+
+	if !reader.Read() {
+		if err := reader.Err(); err != nil {
+			return false, fmt.Errorf("upsert %s: %w", table, err)
+		}
+		return false, nil // mssql: matched but nothing to update
+	}
+	switch v := reader.GetValue2(0).(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strings.EqualFold(v, "INSERT"), nil
+	default:
+		return false, fmt.Errorf("upsert %s: unexpected result %v", table, v)
+	}
+}
+
+// buildUpsert : return statement and arguments, columns are written in name order
+func buildUpsert(d Dialect, table string, keyColumns []string, values, insertOnly map[string]interface{}) (string, []interface{}, error) {
+	if len(keyColumns) == 0 {
+		return "", nil, fmt.Errorf("upsert %s: no key columns", table)
+	}
+	isKey := map[string]bool{}
+	for _, k := range keyColumns {
+		if _, ok := values[k]; !ok {
+			return "", nil, fmt.Errorf("upsert %s: no value for key column %q", table, k)
+		}
+		isKey[k] = true
+	}
+	all := make(map[string]interface{}, len(values)+len(insertOnly))
+	for c, v := range values {
+		all[c] = v
+	}
+	for c, v := range insertOnly {
+		if _, ok := values[c]; ok {
+			return "", nil, fmt.Errorf("upsert %s: column %q is in values and insertOnly", table, c)
+		}
+		all[c] = v
+	}
+
+	columns := sortedKeys(all)
+
+	args := make([]interface{}, len(columns))
+	quoted := make([]string, len(columns))
+	params := make([]string, len(columns))
+	var updates []string
+	for i, c := range columns {
+		args[i] = all[c]
+		quoted[i] = d.QuoteIdent(c)
+		params[i] = d.Placeholder(i + 1)
+		if _, ok := insertOnly[c]; !isKey[c] && !ok {
+			updates = append(updates, quoted[i])
+		}
+	}
+
+	var sb strings.Builder
+	switch d {
+	case DialectPostgres:
+		keys := make([]string, len(keyColumns))
+		for i, k := range keyColumns {
+			keys[i] = d.QuoteIdent(k)
+		}
+		if len(updates) == 0 {
+			updates = keys[:1] // no-op update so RETURNING gives a row
+		}
+		fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET ",
+			d.QuoteIdent(table), strings.Join(quoted, ", "), strings.Join(params, ", "), strings.Join(keys, ", "))
+		for i, c := range updates {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "%s = EXCLUDED.%s", c, c)
+		}
+		sb.WriteString(" RETURNING (xmax = 0) AS inserted")
+
+	case DialectMssql:
+		source := make([]string, len(columns))
+		sourceCols := make([]string, len(columns))
+		for i := range columns {
+			source[i] = params[i] + " AS " + quoted[i]
+			sourceCols[i] = "source." + quoted[i]
+		}
+		on := make([]string, len(keyColumns))
+		for i, k := range keyColumns {
+			on[i] = fmt.Sprintf("target.%s = source.%s", d.QuoteIdent(k), d.QuoteIdent(k))
+		}
+		fmt.Fprintf(&sb, "MERGE INTO %s WITH (HOLDLOCK) AS target USING (SELECT %s) AS source ON %s",
+			d.QuoteIdent(table), strings.Join(source, ", "), strings.Join(on, " AND "))
+		if len(updates) > 0 {
+			sb.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+			for i, c := range updates {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				fmt.Fprintf(&sb, "target.%s = source.%s", c, c)
+			}
+		}
+		fmt.Fprintf(&sb, " WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s) OUTPUT $action;",
+			strings.Join(quoted, ", "), strings.Join(sourceCols, ", "))
+
+	default:
+		return "", nil, fmt.Errorf("upsert %s: unsupported dialect %s", table, d)
+	}
+	return sb.String(), args, nil
+}
@@ -0,0 +1,91 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildUpsert(t *testing.T) {
+	values := map[string]interface{}{"user_id": "U1", "bot_id": 2, "display_name": "Somchai"}
+	cases := []struct {
+		dialect Dialect
+		keys    []string
+		values  map[string]interface{}
+		want    string
+	}{
+		{DialectPostgres, []string{"user_id", "bot_id"}, values,
+			`INSERT INTO "register_user" ("bot_id", "display_name", "user_id") VALUES ($1, $2, $3) ` +
+				`ON CONFLICT ("user_id", "bot_id") DO UPDATE SET "display_name" = EXCLUDED."display_name" ` +
+				`RETURNING (xmax = 0) AS inserted`},
+		{DialectPostgres, []string{"user_id"}, map[string]interface{}{"user_id": "U1"},
+			`INSERT INTO "register_user" ("user_id") VALUES ($1) ` +
+				`ON CONFLICT ("user_id") DO UPDATE SET "user_id" = EXCLUDED."user_id" ` +
+				`RETURNING (xmax = 0) AS inserted`},
+		{DialectMssql, []string{"user_id", "bot_id"}, values,
+			`MERGE INTO [register_user] WITH (HOLDLOCK) AS target ` +
+				`USING (SELECT @p1 AS [bot_id], @p2 AS [display_name], @p3 AS [user_id]) AS source ` +
+				`ON target.[user_id] = source.[user_id] AND target.[bot_id] = source.[bot_id] ` +
+				`WHEN MATCHED THEN UPDATE SET target.[display_name] = source.[display_name] ` +
+				`WHEN NOT MATCHED THEN INSERT ([bot_id], [display_name], [user_id]) ` +
+				`VALUES (source.[bot_id], source.[display_name], source.[user_id]) OUTPUT $action;`},
+	}
+
+	for _, c := range cases {
+		got, args, err := buildUpsert(c.dialect, "register_user", c.keys, c.values, nil)
+		if err != nil {
+			t.Fatalf("buildUpsert(%s) error %v", c.dialect, err)
+		}
+		if got != c.want {
+			t.Errorf("buildUpsert(%s) ==\n%s\nwant\n%s", c.dialect, got, c.want)
+		}
+		if len(args) != len(c.values) {
+			t.Errorf("buildUpsert(%s) args == %v", c.dialect, args)
+		}
+	}
+
+	_, args, _ := buildUpsert(DialectMssql, "register_user", []string{"user_id"}, values, nil)
+	if want := []interface{}{2, "Somchai", "U1"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("buildUpsert args == %v, want %v", args, want)
+	}
+	if _, _, err := buildUpsert(DialectMssql, "register_user", []string{"missing"}, values, nil); err == nil {
+		t.Errorf("buildUpsert with missing key column expect error")
+	}
+}
+
+func TestBuildUpsertInsertOnly(t *testing.T) {
+	values := map[string]interface{}{"user_id": "U1", "display_name": "Somchai"}
+	insertOnly := map[string]interface{}{"create_time": "2021-06-01 00:00:00"}
+	cases := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{DialectPostgres,
+			`INSERT INTO "register_user" ("create_time", "display_name", "user_id") VALUES ($1, $2, $3) ` +
+				`ON CONFLICT ("user_id") DO UPDATE SET "display_name" = EXCLUDED."display_name" ` +
+				`RETURNING (xmax = 0) AS inserted`},
+		{DialectMssql,
+			`MERGE INTO [register_user] WITH (HOLDLOCK) AS target ` +
+				`USING (SELECT @p1 AS [create_time], @p2 AS [display_name], @p3 AS [user_id]) AS source ` +
+				`ON target.[user_id] = source.[user_id] ` +
+				`WHEN MATCHED THEN UPDATE SET target.[display_name] = source.[display_name] ` +
+				`WHEN NOT MATCHED THEN INSERT ([create_time], [display_name], [user_id]) ` +
+				`VALUES (source.[create_time], source.[display_name], source.[user_id]) OUTPUT $action;`},
+	}
+	for _, c := range cases {
+		got, args, err := buildUpsert(c.dialect, "register_user", []string{"user_id"}, values, insertOnly)
+		if err != nil {
+			t.Fatalf("buildUpsert(%s) error %v", c.dialect, err)
+		}
+		if got != c.want {
+			t.Errorf("buildUpsert(%s) ==\n%s\nwant\n%s", c.dialect, got, c.want)
+		}
+		if want := []interface{}{"2021-06-01 00:00:00", "Somchai", "U1"}; !reflect.DeepEqual(args, want) {
+			t.Errorf("buildUpsert(%s) args == %v, want %v", c.dialect, args, want)
+		}
+	}
+
+	if _, _, err := buildUpsert(DialectPostgres, "register_user", []string{"user_id"}, values,
+		map[string]interface{}{"display_name": "x"}); err == nil {
+		t.Errorf("buildUpsert with a column in values and insertOnly expect error")
+	}
+}